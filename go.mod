@@ -5,10 +5,12 @@ go 1.25.1
 require (
 	github.com/playwright-community/playwright-go v0.5200.1
 	github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/deckarep/golang-set/v2 v2.8.0 // indirect
 	github.com/go-jose/go-jose/v3 v3.0.4 // indirect
 	github.com/go-stack/stack v1.8.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
 )
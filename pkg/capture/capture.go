@@ -1,16 +1,34 @@
 package capture
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	pw "github.com/playwright-community/playwright-go"
 )
 
+// Upper bound of client-side routes rendered per page
+const maxRoutes = 50
+
+// Time a client-side route gets to fire its XHR/fetch traffic
+const routeSettle = 750 * time.Millisecond
+
+// CaptureRequests navigates to targetURL, submits its forms, fires the JS triggers and renders every
+// client-side route the app navigates to or its router lists.
+// The discovered routes are returned as absolute URLs.
 func CaptureRequests(
 	page pw.Page,
 	targetURL string,
-) error {
+) ([]string, error) {
+
+	// -------------------------------------------
+	// HOOK HISTORY API (before any page script runs)
+	// -------------------------------------------
+	if err := page.AddInitScript(pw.Script{Content: pw.String(getRouteHookJS())}); err != nil {
+		return nil, fmt.Errorf("route hook injection failed: %w", err)
+	}
 
 	// -------------------------------------------
 	// NAVIGATION
@@ -19,7 +37,23 @@ func CaptureRequests(
 		WaitUntil: pw.WaitUntilStateNetworkidle,
 	})
 	if err != nil {
-		return fmt.Errorf("goto failed: %w", err)
+		return nil, fmt.Errorf("goto failed: %w", err)
+	}
+
+	// -------------------------------------------
+	// FORMS (fill + submit through the real button)
+	// -------------------------------------------
+	// Redirects (trailing slash, login, ...) decide where the forms actually live
+	landingURL := page.URL()
+	submitForms(page, landingURL)
+
+	if page.URL() != landingURL {
+		_, err = page.Goto(landingURL, pw.PageGotoOptions{
+			WaitUntil: pw.WaitUntilStateNetworkidle,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("goto after form submission failed: %w", err)
+		}
 	}
 
 	// -------------------------------------------
@@ -34,6 +68,16 @@ func CaptureRequests(
 		log.Printf("JS trigger execution failed: %v", err)
 	}
 
+	// Child frames, same-origin and cross-origin alike
+	for _, frame := range page.Frames() {
+		if frame.ParentFrame() == nil || frame.IsDetached() {
+			continue
+		}
+		if _, err := frame.Evaluate(triggerJS); err != nil {
+			log.Printf("JS trigger execution in frame %s failed: %v", frame.URL(), err)
+		}
+	}
+
 	// -------------------------------------------
 	// WAIT FOR NETWORKIDLE (after JS)
 	// -------------------------------------------
@@ -42,26 +86,279 @@ func CaptureRequests(
 			State: pw.LoadStateNetworkidle,
 		})
 	if err != nil {
-		return fmt.Errorf("wait for network idle failed: %w", err)
+		return nil, fmt.Errorf("wait for network idle failed: %w", err)
+	}
+
+	// -------------------------------------------
+	// SPA ROUTES
+	// -------------------------------------------
+	routes := visitRoutes(page)
+
+	return routes, nil
+}
+
+// visitRoutes renders every collected client-side route in place, so the per-view traffic ends up in the HAR.
+// Routes discovered while rendering are visited as well, up to maxRoutes.
+func visitRoutes(page pw.Page) []string {
+	visited := make(map[string]bool)
+	visited[page.URL()] = true
+	var all []string
+
+	for len(visited) <= maxRoutes {
+		routes, err := collectRoutes(page)
+		if err != nil {
+			log.Printf("Route collection failed: %v", err)
+			break
+		}
+
+		var fresh []string
+		for _, r := range routes {
+			if !visited[r] {
+				visited[r] = true
+				fresh = append(fresh, r)
+			}
+		}
+		if len(fresh) == 0 {
+			break
+		}
+
+		for _, r := range fresh {
+			if len(all) >= maxRoutes {
+				break
+			}
+			all = append(all, r)
+
+			_, err := page.Evaluate(getRouteNavigateJS(), r)
+			if err != nil {
+				// A route may trigger a real navigation, this is fine
+				log.Printf("Client-side navigation to %s failed: %v", r, err)
+				continue
+			}
+			time.Sleep(routeSettle)
+			page.WaitForLoadState(pw.PageWaitForLoadStateOptions{
+				State: pw.LoadStateNetworkidle,
+			})
+		}
+	}
+
+	return all
+}
+
+// collectRoutes returns routes recorded by the history hook and routes listed by known routers
+func collectRoutes(page pw.Page) ([]string, error) {
+	rawJSON, err := page.Evaluate(getRouteCollectJS())
+	if err != nil {
+		return nil, err
+	}
+
+	jsonStr, ok := rawJSON.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected JSON string, got %T", rawJSON)
+	}
+
+	var routes []string
+	if err := json.Unmarshal([]byte(jsonStr), &routes); err != nil {
+		return nil, err
 	}
+	return routes, nil
+}
+
+func getRouteHookJS() string {
+	return `(() => {
+		if (window.__reqtrackRoutes) return;
+		const routes = window.__reqtrackRoutes = new Set();
+		const record = (u) => {
+			try { routes.add(new URL(u, location.href).href); } catch (e) {}
+		};
 
-	return nil
+		for (const fn of ['pushState', 'replaceState']) {
+			const orig = history[fn];
+			history[fn] = function(state, title, url) {
+				if (url !== undefined && url !== null) record(String(url));
+				return orig.apply(this, arguments);
+			};
+		}
+		window.addEventListener('hashchange', () => record(location.href));
+		window.addEventListener('popstate', () => record(location.href));
+	})();`
+}
+
+func getRouteCollectJS() string {
+	return `() => {
+		const out = new Set(window.__reqtrackRoutes || []);
+		const base = location.origin + location.pathname + location.search;
+		const add = (path, hash) => {
+			// skip dynamic segments like /users/:id or catch-alls
+			if (!path || path.includes(':') || path.includes('*')) return;
+			try { out.add(hash ? base + '#' + path : new URL(path, location.origin).href); } catch (e) {}
+		};
+		const walk = (list, prefix, hash) => {
+			for (const r of list || []) {
+				// pathless layout and index routes only group their children
+				if (typeof r.path !== 'string' || r.path === '') {
+					walk(r.children || r._loadedRoutes, prefix, hash);
+					continue;
+				}
+				let p = r.path;
+				if (!p.startsWith('/')) p = (prefix.endsWith('/') ? prefix : prefix + '/') + p;
+				add(p, hash);
+				// Angular keeps the children of lazy modules in _loadedRoutes once they are loaded
+				walk(r.children, p, hash);
+				walk(r._loadedRoutes, p, hash);
+			}
+		};
+		const hashRouted = location.hash.startsWith('#/') || location.hash.startsWith('#!/');
+
+		// Hash router links
+		for (const a of document.querySelectorAll('a[href^="#/"], a[href^="#!/"]')) {
+			out.add(base + a.getAttribute('href'));
+		}
+
+		for (const el of document.querySelectorAll('*')) {
+			try {
+				// Vue 3
+				const app = el.__vue_app__;
+				if (app && app.config.globalProperties.$router) {
+					const router = app.config.globalProperties.$router;
+					const hash = (router.options.history && String(router.options.history.base).includes('#'));
+					for (const r of router.getRoutes()) add(r.path, hash);
+				}
+				// Vue 2
+				const vm = el.__vue__;
+				if (vm && vm.$router) {
+					walk(vm.$router.options.routes, '/', vm.$router.mode === 'hash');
+				}
+			} catch (e) {}
+		}
+
+		// Angular: the Router is found among the components and directives (window.ng in dev mode)
+		// or in the LView array of __ngContext__, its config lists the routes
+		const isAngularRouter = (v) => v && typeof v === 'object' && Array.isArray(v.config) && typeof v.navigateByUrl === 'function';
+		const angularRouters = new Set();
+		const scan = (obj) => {
+			if (!obj || typeof obj !== 'object') return;
+			for (const v of Object.values(obj)) {
+				if (isAngularRouter(v)) angularRouters.add(v);
+			}
+		};
+		for (const el of document.querySelectorAll('*')) {
+			if (angularRouters.size) break;
+			try {
+				if (window.ng && window.ng.getComponent) {
+					scan(window.ng.getComponent(el));
+					for (const d of (window.ng.getDirectives ? window.ng.getDirectives(el) : [])) scan(d);
+				}
+				if (Array.isArray(el.__ngContext__)) {
+					for (const v of el.__ngContext__) {
+						if (isAngularRouter(v)) angularRouters.add(v); else scan(v);
+					}
+				}
+			} catch (e) {}
+		}
+		for (const router of angularRouters) {
+			try { walk(router.config, '/', hashRouted); } catch (e) {}
+		}
+
+		// React Router: framework mode and Remix ship a route manifest
+		const manifest = (window.__reactRouterManifest || window.__remixManifest || {}).routes;
+		if (manifest) {
+			const byParent = {};
+			for (const r of Object.values(manifest)) (byParent[r.parentId || ''] = byParent[r.parentId || ''] || []).push(r);
+			const tree = (id) => (byParent[id] || []).map(r => ({ path: r.path, children: tree(r.id) }));
+			const basename = (window.__reactRouterContext && window.__reactRouterContext.basename) || '/';
+			walk(tree(''), basename, false);
+		}
+
+		// React Router: data routers (RouterProvider), useRoutes/react-router-config route arrays and
+		// <Route path> elements of <Routes>/<Switch>, found by walking the React fiber tree
+		const routeElements = (children) => {
+			const list = [];
+			for (const c of [].concat(children || [])) {
+				if (!c || typeof c !== 'object' || !c.props) continue;
+				const nested = routeElements(c.props.children);
+				if (typeof c.props.path === 'string') list.push({ path: c.props.path, children: nested });
+				else list.push(...nested);
+			}
+			return list;
+		};
+		const roots = [];
+		for (const el of document.querySelectorAll('*')) {
+			const key = Object.keys(el).find(k => k.startsWith('__reactContainer$'));
+			if (key) roots.push(el[key]);
+			else if (el._reactRootContainer && el._reactRootContainer._internalRoot) roots.push(el._reactRootContainer._internalRoot.current);
+		}
+		const seenRouters = new Set();
+		let budget = 20000;
+		const visitFiber = (fiber) => {
+			for (; fiber && budget > 0; fiber = fiber.sibling) {
+				budget--;
+				const props = fiber.memoizedProps;
+				try {
+					if (props && typeof props === 'object') {
+						const router = props.router;
+						if (router && Array.isArray(router.routes) && !seenRouters.has(router)) {
+							seenRouters.add(router);
+							const hash = typeof router.createHref === 'function' ? String(router.createHref({ pathname: '/' })).startsWith('#') : hashRouted;
+							// top-level paths are relative to the basename, even when they start with a slash
+							const basename = router.basename || '/';
+							const routes = basename === '/' ? router.routes : [{ path: basename, children: router.routes.map(r => ({ ...r, path: typeof r.path === 'string' ? r.path.replace(/^\/+/, '') : r.path })) }];
+							walk(routes, '/', hash);
+						}
+						if (Array.isArray(props.routes)) walk(props.routes, '/', hashRouted);
+						// names are minified in production, so <Routes> is recognized by its <Route path> children
+						if ([].concat(props.children || []).some(c => c && c.props && typeof c.props.path === 'string')) {
+							walk(routeElements(props.children), '/', hashRouted);
+						}
+					}
+				} catch (e) {}
+				visitFiber(fiber.child);
+			}
+		};
+		for (const root of roots) visitFiber(root);
+
+		return JSON.stringify(Array.from(out));
+	}`
+}
+
+// Renders a route without reloading: hash routes via location.hash, history routes via pushState + popstate
+func getRouteNavigateJS() string {
+	return `(u) => {
+		const url = new URL(u);
+		if (url.origin !== location.origin) return;
+		if (url.hash && url.pathname === location.pathname && url.search === location.search) {
+			location.hash = url.hash;
+			return;
+		}
+		history.pushState({}, '', url.href);
+		window.dispatchEvent(new PopStateEvent('popstate', { state: {} }));
+	}`
 }
 
 func getTriggerJS() string {
 	return `(function() {
 		// === 1. Trigger all DOM-related network activity ===
+		// Descend into open shadow roots, web components hide their elements there
+		function deepElements(root, out = []) {
+			for (const el of root.querySelectorAll('*')) {
+				out.push(el);
+				if (el.shadowRoot) deepElements(el.shadowRoot, out);
+			}
+			return out;
+		}
+
+		// Deny-list of the safety guard (logout, delete, pay, ...), absent when no guard is installed
+		const isDenied = window.__reqtrackIsDenied || (() => false);
+		const isDeniedName = window.__reqtrackIsDeniedName || (() => false);
+
 		const evs = ['click','submit','change','mouseover','input'];
-		for(const el of document.querySelectorAll('*')){
+		for(const el of deepElements(document)){
+			if (isDenied(el, 'element')) continue;
 			for(const ev of evs){
 				try { el.dispatchEvent(new Event(ev, {bubbles:true})); }catch(e){}
 			}
 		}
 
-		// Submit all forms
-		for(const form of document.forms){
-			try { form.submit(); } catch(e){}
-		}
+		// Forms were already filled and submitted by submitForms
 
 		// === 2. Execute zero-argument HTTP-related functions ===
 		const httpPattern = /\b(fetch|XMLHttpRequest|axios|\.ajax|sendBeacon|WebSocket|EventSource|Worker|SharedWorker)\b/;
@@ -104,6 +401,7 @@ func getTriggerJS() string {
 		for (const { name, fn } of candidates) {
 			try {
 				if (fn.length === 0) {
+					if (isDeniedName(name, 'function')) continue;
 					console.log("Triggering:", name);
 					fn();
 				}
@@ -0,0 +1,250 @@
+package capture
+
+import (
+	"log"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Upper bound of forms filled and submitted per page
+const maxForms = 20
+
+// submitForms fills every form of targetURL with plausible values and submits it through its
+// real submit button. Forms of child frames and open shadow roots are included. A submit usually
+// navigates the page or its frame away, so the page is restored before each form.
+func submitForms(page pw.Page, targetURL string) {
+	// Frames are recreated whenever the page is restored, so they are found again by URL and position
+	var frameURLs []string
+	for _, frame := range page.Frames() {
+		frameURLs = append(frameURLs, frame.URL())
+	}
+
+	submitted := 0
+	for fi, frameURL := range frameURLs {
+		frame := findFrame(page, frameURLs, fi)
+		if frame == nil {
+			continue
+		}
+		count, err := frame.Evaluate(`() => {` + getDeepFormsJS() + ` return deepForms(document).length; }`)
+		if err != nil {
+			log.Printf("Form lookup in frame %s failed: %v", frameURL, err)
+			continue
+		}
+		n, _ := count.(int)
+
+		for i := 0; i < n; i++ {
+			if submitted == maxForms {
+				log.Printf("Page has more than %d forms, only the first %d are submitted", maxForms, maxForms)
+				return
+			}
+			if page.URL() != targetURL || findFrame(page, frameURLs, fi) == nil {
+				_, err := page.Goto(targetURL, pw.PageGotoOptions{
+					WaitUntil: pw.WaitUntilStateNetworkidle,
+				})
+				if err != nil {
+					log.Printf("Restoring %s for form %d failed: %v", targetURL, i, err)
+					return
+				}
+			}
+			frame := findFrame(page, frameURLs, fi)
+			if frame == nil {
+				log.Printf("Frame %s is gone after restoring %s", frameURL, targetURL)
+				break
+			}
+
+			res, err := frame.Evaluate(getFormFillJS(), i)
+			submitted++
+			if err != nil {
+				// Submitting may destroy the execution context, the request is sent anyway
+				log.Printf("Form %d of %s submit: %v", i, frameURL, err)
+			} else if msg, ok := res.(string); ok && msg != "" {
+				log.Printf("Form %d of %s: %s", i, frameURL, msg)
+			}
+
+			page.WaitForLoadState(pw.PageWaitForLoadStateOptions{
+				State: pw.LoadStateNetworkidle,
+			})
+		}
+	}
+}
+
+// findFrame returns the frame of page that had urls[idx] when urls was taken: the same URL, and as
+// many frames with that URL before it. nil when the frame navigated away or is gone.
+func findFrame(page pw.Page, urls []string, idx int) pw.Frame {
+	nth := 0
+	for _, u := range urls[:idx] {
+		if u == urls[idx] {
+			nth++
+		}
+	}
+	for _, frame := range page.Frames() {
+		if frame.IsDetached() || frame.URL() != urls[idx] {
+			continue
+		}
+		if nth == 0 {
+			return frame
+		}
+		nth--
+	}
+	return nil
+}
+
+// getDeepFormsJS defines deepForms(root), the forms of root in document order followed by those of open shadow roots
+func getDeepFormsJS() string {
+	return `const deepForms = (root, out = []) => {
+			out.push(...root.querySelectorAll('form'));
+			for (const el of root.querySelectorAll('*')) {
+				if (el.shadowRoot) deepForms(el.shadowRoot, out);
+			}
+			return out;
+		};`
+}
+
+// getFormFillJS fills form number idx of deepForms type-aware and submits it, returns a short status message
+func getFormFillJS() string {
+	return `(idx) => {
+		` + getDeepFormsJS() + `
+		const form = deepForms(document)[idx];
+		if (!form) return "";
+
+		// Deny-list of the safety guard, checked on the form itself and on its submit button
+		const isDenied = window.__reqtrackIsDenied || (() => false);
+		const btn = Array.from(form.elements).find(el => el.type === "submit" || el.type === "image");
+		if (isDenied(form, "form") || (btn && isDenied(btn, "form"))) return "skipped by deny-list";
+
+		const samples = {
+			email: "reqtrack@example.com",
+			tel: "+15555550100",
+			url: "https://example.com/",
+			password: "Reqtrack1!",
+			date: "2024-01-15",
+			"datetime-local": "2024-01-15T12:00",
+			time: "12:00",
+			month: "2024-01",
+			week: "2024-W03",
+			color: "#336699",
+			search: "reqtrack",
+			text: "reqtrack",
+		};
+
+		// Guess from name/id/placeholder when the type is generic
+		const byName = (el) => {
+			const hint = (el.name + " " + el.id + " " + (el.placeholder || "") + " " + (el.autocomplete || "")).toLowerCase();
+			if (/mail/.test(hint)) return samples.email;
+			if (/phone|tel|mobile/.test(hint)) return samples.tel;
+			if (/url|website|homepage/.test(hint)) return samples.url;
+			if (/zip|postal|plz/.test(hint)) return "12345";
+			if (/year/.test(hint)) return "2024";
+			if (/age|qty|quantity|amount|count|number/.test(hint)) return "1";
+			if (/date|birth/.test(hint)) return samples.date;
+			if (/first/.test(hint)) return "John";
+			if (/last|surname/.test(hint)) return "Doe";
+			if (/city/.test(hint)) return "Berlin";
+			if (/country/.test(hint)) return "DE";
+			if (/user|login|name/.test(hint)) return "reqtrack";
+			return samples.text;
+		};
+
+		// Respect pattern, minlength and maxlength
+		const fit = (el, v) => {
+			if (el.pattern) {
+				let re;
+				try { re = new RegExp("^(?:" + el.pattern + ")$"); } catch (e) {}
+				if (re && !re.test(v)) {
+					const candidates = ["12345", "1", "abc", "ABC", "abc123", "ABC123", "a1", samples.email, samples.tel, samples.date];
+					v = candidates.find(c => re.test(c)) || v;
+				}
+			}
+			const min = el.minLength > 0 ? el.minLength : 0;
+			while (v.length < min) v += "x";
+			if (el.maxLength > 0 && v.length > el.maxLength) v = v.slice(0, el.maxLength);
+			return v;
+		};
+
+		// Native setter so frameworks tracking the value property (React) notice the change
+		const setValue = (el, v) => {
+			const proto = Object.getPrototypeOf(el);
+			const desc = Object.getOwnPropertyDescriptor(proto, "value");
+			if (desc && desc.set) desc.set.call(el, v); else el.value = v;
+			el.dispatchEvent(new Event("input", { bubbles: true }));
+			el.dispatchEvent(new Event("change", { bubbles: true }));
+		};
+
+		const radiosDone = new Set();
+		let filled = 0;
+
+		for (const el of form.elements) {
+			if (el.disabled || el.readOnly) continue;
+			const tag = el.tagName.toLowerCase();
+			const type = (el.type || "").toLowerCase();
+
+			try {
+				if (tag === "select") {
+					const opt = Array.from(el.options).find(o => !o.disabled && o.value !== "");
+					if (opt) {
+						if (el.multiple) opt.selected = true; else setValue(el, opt.value);
+						el.dispatchEvent(new Event("change", { bubbles: true }));
+						filled++;
+					}
+					continue;
+				}
+				if (tag === "textarea") {
+					if (!el.value) { setValue(el, fit(el, "reqtrack")); filled++; }
+					continue;
+				}
+				if (tag !== "input") continue;
+
+				switch (type) {
+				case "hidden": case "submit": case "button": case "reset": case "image":
+					break;
+				case "checkbox":
+					if (!el.checked) { el.click(); filled++; }
+					break;
+				case "radio":
+					if (!radiosDone.has(el.name)) {
+						radiosDone.add(el.name);
+						const group = form.querySelectorAll('input[type="radio"][name="' + CSS.escape(el.name) + '"]');
+						if (!Array.from(group).some(r => r.checked)) { el.click(); filled++; }
+					}
+					break;
+				case "file": {
+					const accept = (el.accept || "").toLowerCase();
+					const file = /image/.test(accept)
+						? new File([Uint8Array.from(atob("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="), c => c.charCodeAt(0))], "reqtrack.png", { type: "image/png" })
+						: new File(["reqtrack"], "reqtrack.txt", { type: "text/plain" });
+					const dt = new DataTransfer();
+					dt.items.add(file);
+					el.files = dt.files;
+					el.dispatchEvent(new Event("change", { bubbles: true }));
+					filled++;
+					break;
+				}
+				case "number": case "range": {
+					if (el.value && type === "number") break;
+					let v = el.min !== "" ? Number(el.min) : 1;
+					if (el.max !== "" && v > Number(el.max)) v = Number(el.max);
+					setValue(el, String(v));
+					filled++;
+					break;
+				}
+				default:
+					if (el.value) break;
+					setValue(el, fit(el, samples[type] && type !== "text" && type !== "search" ? samples[type] : byName(el)));
+					filled++;
+				}
+			} catch (e) {}
+		}
+
+		const valid = form.checkValidity();
+
+		// Real submit button first, so click and submit handlers as well as validation run like for a user
+		if (btn) {
+			btn.click();
+		} else if (form.requestSubmit) {
+			form.requestSubmit();
+		} else {
+			form.submit();
+		}
+		return "filled " + filled + " fields" + (valid ? "" : ", validation still failing") + (btn ? ", submitted via button" : ", submitted via requestSubmit");
+	}`
+}
@@ -0,0 +1,238 @@
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Cookie of a JSON export. Covers browser extensions (expirationDate, hostOnly, no_restriction)
+// as well as Playwright (expires, Lax/Strict/None).
+type jsonCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Expires        *float64 `json:"expires"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	HttpOnly       bool     `json:"httpOnly"`
+	Secure         bool     `json:"secure"`
+	SameSite       string   `json:"sameSite"`
+	HostOnly       bool     `json:"hostOnly"`
+}
+
+// Load reads a Netscape cookies.txt or a JSON cookie export, the format is detected from the content
+func Load(path string) ([]pw.OptionalCookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return parseJSON(trimmed)
+	}
+	return parseNetscape(data)
+}
+
+// ParseHeader turns a raw "Cookie: a=b; c=d" header (the "Cookie:" prefix is optional)
+// into cookies bound to the origin of targetURL, so they never leak to other hosts.
+func ParseHeader(raw string, targetURL string) []pw.OptionalCookie {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > 7 && strings.EqualFold(raw[:7], "cookie:") {
+		raw = raw[7:]
+	}
+
+	var out []pw.OptionalCookie
+	for _, part := range strings.Split(raw, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || name == "" {
+			continue
+		}
+		out = append(out, pw.OptionalCookie{
+			Name:  strings.TrimSpace(name),
+			Value: strings.TrimSpace(value),
+			URL:   pw.String(targetURL),
+		})
+	}
+	return out
+}
+
+func parseJSON(data []byte) ([]pw.OptionalCookie, error) {
+	var list []jsonCookie
+	if data[0] == '{' {
+		// Playwright storage state: {"cookies": [...], "origins": [...]}
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		list = state.Cookies
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	out := make([]pw.OptionalCookie, 0, len(list))
+	for _, c := range list {
+		if c.Name == "" || c.Domain == "" {
+			continue
+		}
+		domain := c.Domain
+		// Extension exports mark domain cookies with hostOnly=false but no leading dot
+		if !c.HostOnly && !strings.HasPrefix(domain, ".") && c.Expires == nil {
+			domain = "." + domain
+		}
+		p := c.Path
+		if p == "" {
+			p = "/"
+		}
+		oc := pw.OptionalCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   pw.String(domain),
+			Path:     pw.String(p),
+			HttpOnly: pw.Bool(c.HttpOnly),
+			Secure:   pw.Bool(c.Secure),
+			SameSite: sameSite(c.SameSite),
+		}
+		if c.Expires != nil && *c.Expires > 0 {
+			oc.Expires = pw.Float(*c.Expires)
+		}
+		if c.ExpirationDate != nil && *c.ExpirationDate > 0 {
+			oc.Expires = pw.Float(*c.ExpirationDate)
+		}
+		out = append(out, oc)
+	}
+	return out, nil
+}
+
+// parseNetscape reads the curl/wget cookies.txt format:
+// domain, include subdomains, path, secure, expiry, name, value separated by tabs
+func parseNetscape(data []byte) ([]pw.OptionalCookie, error) {
+	var out []pw.OptionalCookie
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimRight(sc.Text(), "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("cookies.txt line %d: expected 7 tab separated fields, got %d", lineNo, len(fields))
+		}
+		oc := pw.OptionalCookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   pw.String(fields[0]),
+			Path:     pw.String(fields[2]),
+			HttpOnly: pw.Bool(httpOnly),
+			Secure:   pw.Bool(strings.EqualFold(fields[3], "TRUE")),
+		}
+		if exp, err := strconv.ParseFloat(fields[4], 64); err == nil && exp > 0 {
+			oc.Expires = pw.Float(exp)
+		}
+		out = append(out, oc)
+	}
+	return out, sc.Err()
+}
+
+// Save writes cookies as JSON when path ends with .json, otherwise as Netscape cookies.txt
+func Save(path string, cookies []pw.Cookie) error {
+	if cookies == nil {
+		cookies = []pw.Cookie{}
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		data, err := json.MarshalIndent(cookies, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o600)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n# Written by reqtrack\n\n")
+	for _, c := range cookies {
+		prefix := ""
+		if c.HttpOnly {
+			prefix = "#HttpOnly_"
+		}
+		expires := int64(0)
+		if c.Expires > 0 {
+			expires = int64(c.Expires)
+		}
+		fmt.Fprintf(&b, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			prefix, c.Domain, boolString(strings.HasPrefix(c.Domain, ".")), c.Path,
+			boolString(c.Secure), expires, c.Name, c.Value)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
+
+// ToOptional converts a cookie read from a context into one that can be added to a context again
+func ToOptional(c pw.Cookie) pw.OptionalCookie {
+	oc := pw.OptionalCookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   pw.String(c.Domain),
+		Path:     pw.String(c.Path),
+		HttpOnly: pw.Bool(c.HttpOnly),
+		Secure:   pw.Bool(c.Secure),
+		SameSite: c.SameSite,
+	}
+	// -1 marks session cookies
+	if c.Expires > 0 {
+		oc.Expires = pw.Float(c.Expires)
+	}
+	return oc
+}
+
+func sameSite(v string) *pw.SameSiteAttribute {
+	switch strings.ToLower(v) {
+	case "strict":
+		return pw.SameSiteAttributeStrict
+	case "lax":
+		return pw.SameSiteAttributeLax
+	case "none", "no_restriction":
+		return pw.SameSiteAttributeNone
+	}
+	return nil
+}
+
+func boolString(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// SaveState writes storage states (cookies and localStorage per origin) as one Playwright
+// storage-state file that can be loaded again with -storage-state
+func SaveState(path string, states []*pw.StorageState) error {
+	merged := pw.StorageState{Cookies: []pw.Cookie{}, Origins: []pw.Origin{}}
+	for _, s := range states {
+		if s == nil {
+			continue
+		}
+		merged.Cookies = append(merged.Cookies, s.Cookies...)
+		merged.Origins = append(merged.Origins, s.Origins...)
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
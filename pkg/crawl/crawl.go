@@ -0,0 +1,309 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/m-1tZ/reqtrack/pkg/capture"
+	"github.com/m-1tZ/reqtrack/pkg/csrf"
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/safety"
+	"github.com/m-1tZ/reqtrack/pkg/scope"
+	"github.com/m-1tZ/reqtrack/pkg/scrape"
+	"github.com/m-1tZ/reqtrack/pkg/session"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Options controls how far the crawler wanders away from its seeds.
+type Options struct {
+	MaxDepth     int              // link hops from a seed, 0 only visits the seeds
+	MaxPages     int              // total pages visited, <= 0 means unlimited
+	NavTimeout   float64          // milliseconds
+	ParseTimeout float64          // seconds
+	Scope        *scope.Scope     // nil restricts the crawl to the origins of the seeds
+	Guard        *safety.Guard    // optional, restores the session after a detected logout
+	Session      *session.Monitor // optional, re-authenticates after a logout signal
+	CSRF         *csrf.Collector  // optional, collects anti-CSRF tokens of every page
+	Headers      *headers.Set     // sent with external script fetches
+}
+
+// Result of a crawl
+type Result struct {
+	Entries []*structs.HAREntry // statically scraped entries
+	Frames  map[string]string   // "METHOD URL" of requests issued by child frames -> frame URL
+
+	mu sync.Mutex
+}
+
+// AttributeFrames marks every entry that was issued by a child frame with the frame's URL
+func (r *Result) AttributeFrames(entries []*structs.HAREntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range entries {
+		if f, ok := r.Frames[e.Request.Method+" "+e.Request.URL]; ok && e.Frame == "" {
+			e.Frame = f
+		}
+	}
+}
+
+// queued page waiting to be visited
+type target struct {
+	URL     string
+	Depth   int
+	Retried bool // captured again after a re-authentication
+}
+
+// Static resources that are never worth visiting as a page
+var skipExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true, ".webp": true,
+	".css": true, ".js": true, ".map": true, ".woff": true, ".woff2": true, ".ttf": true, ".eot": true,
+	".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".mp3": true, ".mp4": true, ".webm": true,
+}
+
+// Crawl visits the seeds breadth-first, runs the capture and scrape phase on every
+// page and queues in-scope links, form actions and client-side navigations it finds.
+// Dynamic traffic lands in the HAR of browserCtx, the statically scraped entries are returned.
+func Crawl(
+	browserCtx pw.BrowserContext,
+	seeds []string,
+	opts Options,
+) (*Result, error) {
+
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no seeds to crawl")
+	}
+
+	origins := make(map[string]bool)
+	visited := make(map[string]bool)
+	var queue []target
+
+	for _, s := range seeds {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q: %w", s, err)
+		}
+		origins[u.Scheme+"://"+u.Host] = true
+		queue = append(queue, target{URL: s, Depth: 0})
+	}
+
+	res := &Result{Frames: make(map[string]string)}
+	pages := 0
+
+	for len(queue) > 0 {
+		if opts.MaxPages > 0 && pages >= opts.MaxPages {
+			log.Printf("Page budget of %d reached, %d queued pages skipped", opts.MaxPages, len(queue))
+			break
+		}
+
+		t := queue[0]
+		queue = queue[1:]
+
+		key := normalizeLink(t.URL)
+		if key == "" || visited[key] {
+			continue
+		}
+		visited[key] = true
+		pages++
+
+		log.Printf("Crawling [%d/%d] %s", t.Depth, opts.MaxDepth, t.URL)
+		entries, links, err := visit(browserCtx, t.URL, opts, res)
+		if opts.Session.Check(browserCtx) {
+			opts.Guard.ForgetSession()
+			if !t.Retried {
+				// Resume where the session was lost, the page does not count against the budget again
+				delete(visited, key)
+				pages--
+				queue = append([]target{{URL: t.URL, Depth: t.Depth, Retried: true}}, queue...)
+			}
+		}
+		opts.Guard.CheckSession(browserCtx)
+		if err != nil {
+			log.Printf("Visit of %s failed: %v", t.URL, err)
+			continue
+		}
+		res.Entries = append(res.Entries, entries...)
+
+		if t.Depth >= opts.MaxDepth {
+			continue
+		}
+		for _, l := range links {
+			n := normalizeLink(l)
+			if n == "" || visited[n] {
+				continue
+			}
+			if opts.Scope != nil && !opts.Scope.InScope(n) {
+				continue
+			}
+			if opts.Scope == nil && !sameOrigin(n, origins) {
+				continue
+			}
+			queue = append(queue, target{URL: n, Depth: t.Depth + 1})
+		}
+	}
+
+	return res, nil
+}
+
+// visit runs capture + scrape on a fresh page and returns the scraped entries and discovered links
+func visit(
+	browserCtx pw.BrowserContext,
+	targetURL string,
+	opts Options,
+	res *Result,
+) ([]*structs.HAREntry, []string, error) {
+
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer page.Close()
+
+	// Client-side navigations triggered by the capture phase (clicks, form submits, location changes)
+	var mu sync.Mutex
+	var navigated []string
+	page.OnFrameNavigated(func(f pw.Frame) {
+		if f.ParentFrame() != nil {
+			return
+		}
+		mu.Lock()
+		navigated = append(navigated, f.URL())
+		mu.Unlock()
+	})
+
+	// Requests of child frames, so findings can be attributed to the frame they came from
+	page.OnRequest(func(r pw.Request) {
+		f := r.Frame()
+		if f == nil || f.ParentFrame() == nil {
+			return
+		}
+		res.mu.Lock()
+		res.Frames[r.Method()+" "+r.URL()] = f.URL()
+		res.mu.Unlock()
+	})
+
+	// ---- CAPTURE (navigate + JS triggers + SPA routes) ----
+	routes, err := capture.CaptureRequests(page, targetURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Forms and frames the interactions opened are gone once ScrapeRequests navigates
+	opts.CSRF.Inspect(page)
+
+	// ---- SCRAPE (static / heuristics) ----
+	entries, err := scrape.ScrapeRequests(page, browserCtx, targetURL, opts.NavTimeout, opts.ParseTimeout, opts.Scope, opts.Headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ScrapeRequests navigated back to targetURL, so the DOM is fresh again
+	links, err := ExtractLinks(page)
+	if err != nil {
+		log.Printf("Link extraction on %s failed: %v", targetURL, err)
+	}
+
+	opts.Session.Inspect(page)
+	opts.CSRF.Inspect(page)
+
+	mu.Lock()
+	links = append(links, navigated...)
+	mu.Unlock()
+	links = append(links, routes...)
+
+	return entries, links, nil
+}
+
+// ExtractLinks returns the absolute URLs of anchors, form actions and frames of every frame of the current page,
+// open shadow roots included
+func ExtractLinks(page pw.Page) ([]string, error) {
+	var links []string
+	for _, frame := range page.Frames() {
+		if frame.IsDetached() {
+			continue
+		}
+		found, err := extractFrameLinks(frame)
+		if err != nil {
+			if frame.ParentFrame() == nil {
+				return nil, err
+			}
+			log.Printf("Link extraction in frame %s failed: %v", frame.URL(), err)
+			continue
+		}
+		links = append(links, found...)
+	}
+	return links, nil
+}
+
+func extractFrameLinks(frame pw.Frame) ([]string, error) {
+	rawJSON, err := frame.Evaluate(`() => {
+		const out = [];
+		// Descend into open shadow roots
+		const deepQuery = (root, sel, out = []) => {
+			out.push(...root.querySelectorAll(sel));
+			for (const el of root.querySelectorAll('*')) {
+				if (el.shadowRoot) deepQuery(el.shadowRoot, sel, out);
+			}
+			return out;
+		};
+		for (const a of deepQuery(document, 'a[href], area[href]')) {
+			out.push(a.href);
+		}
+		for (const f of deepQuery(document, 'form')) {
+			// form.action resolves to the document URL when the attribute is missing
+			out.push(f.action);
+		}
+		for (const f of deepQuery(document, 'iframe[src], frame[src]')) {
+			out.push(f.src);
+		}
+		for (const el of deepQuery(document, '[data-href], [data-url], [routerlink], [ng-href]')) {
+			const v = el.getAttribute('data-href') || el.getAttribute('data-url') ||
+				el.getAttribute('routerlink') || el.getAttribute('ng-href');
+			try { out.push(new URL(v, document.baseURI).href); } catch (e) {}
+		}
+		return JSON.stringify(out);
+	}`)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonStr, ok := rawJSON.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected JSON string, got %T", rawJSON)
+	}
+
+	var links []string
+	if err := json.Unmarshal([]byte(jsonStr), &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// normalizeLink drops fragments and non-page resources, returns "" for links that should not be visited
+func normalizeLink(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	if skipExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+func sameOrigin(raw string, origins map[string]bool) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return origins[u.Scheme+"://"+u.Host]
+}
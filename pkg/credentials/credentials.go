@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// ParseHTTPAuth parses "[origin=]user:password" into Basic/Digest credentials.
+// Without an origin they are bound to each target by ForTarget.
+func ParseHTTPAuth(raw string) (*pw.HttpCredentials, error) {
+	origin, creds, err := splitOrigin(raw)
+	if err != nil {
+		return nil, err
+	}
+	user, pass, ok := strings.Cut(creds, ":")
+	if !ok || user == "" {
+		return nil, fmt.Errorf("invalid HTTP auth %q, expected [origin=]user:password", raw)
+	}
+	c := &pw.HttpCredentials{Username: user, Password: pass}
+	if origin != "" {
+		c.Origin = pw.String(origin)
+	}
+	return c, nil
+}
+
+// ParseClientCert parses "origin=cert.pem,key.pem[,passphrase]" or "origin=client.p12[,passphrase]"
+func ParseClientCert(raw string) (pw.ClientCertificate, error) {
+	origin, files, err := splitOrigin(raw)
+	if err != nil {
+		return pw.ClientCertificate{}, err
+	}
+	if origin == "" {
+		return pw.ClientCertificate{}, fmt.Errorf("client certificate %q needs an origin, e.g. https://host=cert.pem,key.pem", raw)
+	}
+
+	parts := strings.Split(files, ",")
+	cert := pw.ClientCertificate{Origin: origin}
+	switch ext := strings.ToLower(filepath.Ext(parts[0])); {
+	case ext == ".pfx" || ext == ".p12":
+		cert.PfxPath = pw.String(parts[0])
+		parts = parts[1:]
+	case len(parts) >= 2:
+		cert.CertPath = pw.String(parts[0])
+		cert.KeyPath = pw.String(parts[1])
+		parts = parts[2:]
+	default:
+		return pw.ClientCertificate{}, fmt.Errorf("client certificate %q needs a certificate and a key, or a PFX file", raw)
+	}
+	if len(parts) > 0 {
+		cert.Passphrase = pw.String(strings.Join(parts, ","))
+	}
+
+	for _, p := range []*string{cert.PfxPath, cert.CertPath, cert.KeyPath} {
+		if p == nil {
+			continue
+		}
+		if _, err := os.Stat(*p); err != nil {
+			return pw.ClientCertificate{}, err
+		}
+	}
+	return cert, nil
+}
+
+// splitOrigin splits an optional "scheme://host[:port]=" prefix, the origin is normalized (path dropped)
+func splitOrigin(raw string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		return "", raw, nil
+	}
+	origin, rest, ok := strings.Cut(raw, "=")
+	if !ok {
+		return "", "", fmt.Errorf("missing \"=\" after origin in %q", raw)
+	}
+	origin, err := helper.Origin(origin)
+	if err != nil {
+		return "", "", err
+	}
+	return origin, rest, nil
+}
+
+// ForTarget returns creds bound to the origin of targetURL unless an origin was given,
+// so challenges of third-party hosts the page loads are never answered
+func ForTarget(creds *pw.HttpCredentials, targetURL string) (*pw.HttpCredentials, error) {
+	if creds == nil || creds.Origin != nil {
+		return creds, nil
+	}
+	origin, err := helper.Origin(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	c := *creds
+	c.Origin = pw.String(origin)
+	return &c, nil
+}
@@ -0,0 +1,334 @@
+package csrf
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Names of meta tags, form fields, cookies and headers that carry anti-CSRF tokens
+var tokenName = regexp.MustCompile(`(?i)csrf|xsrf|authenticity_token|requestverificationtoken|^_token$|anti.?forgery`)
+
+// Header the token is sent in when the app never showed how it sends it
+const defaultHeader = "X-CSRF-Token"
+
+// Token is an anti-CSRF token of one host
+type Token struct {
+	Value  string
+	Source string // meta, input, cookie or header
+	Name   string // name it was found under
+	Param  string // body/query parameter the app sends it in, from Rails' csrf-param or an observed request
+	Header string // header the app sends it in, from Spring's _csrf_header or an observed request
+}
+
+// found by the page script
+type pageToken struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Param  string `json:"param"`
+	Header string `json:"header"`
+}
+
+// Collector gathers CSRF tokens of every host during capture and attaches them to requests that need one
+type Collector struct {
+	mu     sync.Mutex
+	tokens map[string]*Token // host -> latest token
+}
+
+func New() *Collector {
+	return &Collector{tokens: make(map[string]*Token)}
+}
+
+// Install watches response headers of browserCtx for tokens
+func (c *Collector) Install(browserCtx pw.BrowserContext) error {
+	if c == nil {
+		return nil
+	}
+	browserCtx.OnResponse(func(r pw.Response) {
+		for name, value := range r.Headers() {
+			if value != "" && tokenName.MatchString(name) && !strings.HasPrefix(name, "access-control-") {
+				c.add(helper.Hostname(r.URL()), &Token{Value: value, Source: "header", Name: name, Header: name})
+			}
+		}
+	})
+	return nil
+}
+
+// Inspect collects tokens from meta tags and hidden form fields of every frame of a page, open shadow
+// roots included. Tokens of a frame belong to the frame's own host.
+func (c *Collector) Inspect(page pw.Page) {
+	if c == nil {
+		return
+	}
+	for _, frame := range page.Frames() {
+		c.inspectFrame(frame)
+	}
+}
+
+func (c *Collector) inspectFrame(frame pw.Frame) {
+	host := helper.Hostname(frame.URL())
+	if host == "" {
+		// about:blank and srcdoc frames
+		return
+	}
+	raw, err := frame.Evaluate(getTokenJS())
+	if err != nil {
+		log.Printf("CSRF: inspecting %s failed: %v", frame.URL(), err)
+		return
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return
+	}
+	var found []pageToken
+	if err := json.Unmarshal([]byte(s), &found); err != nil {
+		return
+	}
+	for _, t := range found {
+		if t.Value == "" || !tokenName.MatchString(t.Name) {
+			continue
+		}
+		c.add(host, &Token{Value: t.Value, Source: t.Source, Name: t.Name, Param: t.Param, Header: t.Header})
+	}
+}
+
+// CollectCookies picks up token cookies such as XSRF-TOKEN (Angular) or csrftoken (Django).
+// Called before the context is closed.
+func (c *Collector) CollectCookies(browserCtx pw.BrowserContext) {
+	if c == nil {
+		return
+	}
+	jar, err := browserCtx.Cookies()
+	if err != nil {
+		log.Printf("CSRF: reading cookies failed: %v", err)
+		return
+	}
+	for _, ck := range jar {
+		if !tokenName.MatchString(ck.Name) {
+			continue
+		}
+		t := &Token{Value: ck.Value, Source: "cookie", Name: ck.Name}
+		// Angular and axios echo XSRF-TOKEN in X-XSRF-TOKEN
+		if strings.EqualFold(ck.Name, "XSRF-TOKEN") {
+			t.Header = "X-XSRF-TOKEN"
+		}
+		c.add(strings.TrimPrefix(ck.Domain, "."), t)
+	}
+}
+
+// add stores a token, details learned earlier (parameter, header) survive a new value
+func (c *Collector) add(host string, t *Token) {
+	host = strings.ToLower(host)
+	if host == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev, ok := c.tokens[host]; ok {
+		// Explicit tokens of the page win over cookies, which may be stale
+		if prev.Source != "cookie" && t.Source == "cookie" && prev.Value != t.Value {
+			return
+		}
+		if t.Param == "" {
+			t.Param = prev.Param
+		}
+		if t.Header == "" {
+			t.Header = prev.Header
+		}
+	}
+	c.tokens[host] = t
+}
+
+// tokenFor returns the token of host or of a parent domain, cookies are often set for the whole site
+func (c *Collector) tokenFor(host string) *Token {
+	host = strings.ToLower(host)
+	for host != "" {
+		if t, ok := c.tokens[host]; ok {
+			return t
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			return nil
+		}
+		host = parent
+	}
+	return nil
+}
+
+// Apply learns from the browser's requests where the app sends its token and marks them,
+// then attaches the token to every state-changing entry of pending (scraped, failed or
+// blocked requests) to a host with a token.
+func (c *Collector) Apply(observed []*structs.HAREntry, pending []*structs.HAREntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.tokens) == 0 {
+		return
+	}
+
+	for _, e := range observed {
+		t := c.tokenFor(helper.Hostname(e.Request.URL))
+		if t == nil {
+			continue
+		}
+		if where, name := locate(&e.Request, t.Value); where != "" {
+			if where == "header" {
+				t.Header = name
+			} else {
+				t.Param = name
+			}
+			e.CSRF = &structs.HARCSRF{Name: name, In: where, Source: t.Source}
+		}
+	}
+
+	attached := 0
+	for _, e := range pending {
+		req := &e.Request
+		if !stateChanging(req.Method) {
+			continue
+		}
+		t := c.tokenFor(helper.Hostname(req.URL))
+		if t == nil {
+			continue
+		}
+		if where, name := locate(req, t.Value); where != "" {
+			e.CSRF = &structs.HARCSRF{Name: name, In: where, Source: t.Source}
+			continue
+		}
+		e.CSRF = attach(req, t)
+		attached++
+	}
+	if attached > 0 {
+		log.Printf("CSRF: token attached to %d requests", attached)
+	}
+}
+
+// locate returns where a request carries the token value: header, query or body, and under which name
+func locate(req *structs.HARRequest, value string) (string, string) {
+	for _, h := range req.Headers {
+		if h.Value == value {
+			return "header", h.Name
+		}
+	}
+	for _, q := range req.Query {
+		if q.Value == value {
+			return "query", q.Name
+		}
+	}
+	if req.PostData == nil {
+		return "", ""
+	}
+	for _, p := range req.PostData.Params {
+		if p.Value == value {
+			return "body", p.Name
+		}
+	}
+	if form, err := url.ParseQuery(req.PostData.Text); err == nil {
+		for name, values := range form {
+			for _, v := range values {
+				if v == value {
+					return "body", name
+				}
+			}
+		}
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(req.PostData.Text), &obj) == nil {
+		for name, v := range obj {
+			if s, ok := v.(string); ok && s == value {
+				return "body", name
+			}
+		}
+	}
+	return "", ""
+}
+
+// attach adds the token the way the app sends it: as form or JSON field when the parameter
+// is known and the body allows it, as header otherwise
+func attach(req *structs.HARRequest, t *Token) *structs.HARCSRF {
+	if t.Param != "" && req.PostData != nil {
+		mime := strings.ToLower(req.PostData.MimeType)
+		switch {
+		case strings.Contains(mime, "x-www-form-urlencoded"):
+			sep := "&"
+			if req.PostData.Text == "" {
+				sep = ""
+			}
+			req.PostData.Text += sep + url.QueryEscape(t.Param) + "=" + url.QueryEscape(t.Value)
+			req.BodySize = len(req.PostData.Text)
+			return &structs.HARCSRF{Name: t.Param, In: "body", Source: t.Source}
+		case strings.Contains(mime, "json"):
+			var obj map[string]interface{}
+			if json.Unmarshal([]byte(req.PostData.Text), &obj) == nil {
+				obj[t.Param] = t.Value
+				if b, err := json.Marshal(obj); err == nil {
+					req.PostData.Text = string(b)
+					req.BodySize = len(b)
+					return &structs.HARCSRF{Name: t.Param, In: "body", Source: t.Source}
+				}
+			}
+		}
+	}
+
+	header := t.Header
+	if header == "" {
+		header = defaultHeader
+	}
+	if !helper.HasHeader(req.Headers, header) {
+		req.Headers = append(req.Headers, structs.HARNameValue{Name: header, Value: t.Value})
+	}
+	return &structs.HARCSRF{Name: header, In: "header", Source: t.Source}
+}
+
+func stateChanging(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+func getTokenJS() string {
+	return `() => {
+		const out = [];
+		const meta = (name) => {
+			const el = document.querySelector('meta[name="' + name + '"]');
+			return el ? el.getAttribute('content') || '' : '';
+		};
+
+		// Rails: csrf-token + csrf-param, Spring Security: _csrf + _csrf_header (+ _csrf_parameter)
+		const rails = meta('csrf-token');
+		if (rails) out.push({ source: 'meta', name: 'csrf-token', value: rails, param: meta('csrf-param') });
+		const spring = meta('_csrf');
+		if (spring) out.push({ source: 'meta', name: '_csrf', value: spring, header: meta('_csrf_header'), param: meta('_csrf_parameter') });
+		for (const el of document.querySelectorAll('meta[name]')) {
+			const name = el.getAttribute('name');
+			// Already handled, or naming the parameter/header instead of holding a token
+			if (['csrf-token', 'csrf-param', '_csrf', '_csrf_header', '_csrf_parameter'].includes(name)) continue;
+			out.push({ source: 'meta', name, value: el.getAttribute('content') || '' });
+		}
+
+		// Hidden fields of forms, descending into open shadow roots
+		const deepQuery = (root, sel, acc = []) => {
+			acc.push(...root.querySelectorAll(sel));
+			for (const el of root.querySelectorAll('*')) {
+				if (el.shadowRoot) deepQuery(el.shadowRoot, sel, acc);
+			}
+			return acc;
+		};
+		for (const el of deepQuery(document, 'input[type="hidden"][name]')) {
+			out.push({ source: 'input', name: el.name, value: el.value, param: el.name });
+		}
+		return JSON.stringify(out);
+	}`
+}
@@ -0,0 +1,286 @@
+package discover
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Upper bound of sitemaps fetched, sitemap indexes can nest a lot of them
+const maxSitemaps = 20
+
+// Well-known files worth fetching besides robots.txt and sitemap.xml
+var wellKnownPaths = []string{
+	"/.well-known/security.txt",
+	"/security.txt",
+	"/.well-known/openid-configuration",
+	"/.well-known/oauth-authorization-server",
+	"/.well-known/change-password",
+	"/.well-known/assetlinks.json",
+	"/.well-known/apple-app-site-association",
+	"/manifest.json",
+	"/manifest.webmanifest",
+}
+
+// Sitemap covers both <urlset> and <sitemapindex> documents
+type sitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// discoverer collects seeds from robots.txt, sitemaps and well-known files of one origin
+type discoverer struct {
+	request    pw.APIRequestContext
+	headers    *headers.Set
+	baseOrigin string
+	navTimeout float64
+
+	seen    map[string]bool
+	seeds   []string
+	entries []*structs.HAREntry
+}
+
+// Discover fetches robots.txt, sitemap.xml (including sitemap indexes), well-known files and
+// web app manifests of the target's origin through the APIRequestContext of browserCtx.
+// It returns the URLs found as crawl seeds and HAR entries tagged with their discovery source.
+func Discover(
+	browserCtx pw.BrowserContext,
+	targetURL string,
+	navTimeout float64,
+	hdr *headers.Set,
+) ([]string, []*structs.HAREntry, error) {
+
+	parsedBase, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d := &discoverer{
+		request:    browserCtx.Request(),
+		headers:    hdr,
+		baseOrigin: parsedBase.Scheme + "://" + parsedBase.Host,
+		navTimeout: navTimeout,
+		seen:       make(map[string]bool),
+	}
+
+	// ---- robots.txt ----
+	sitemaps := []string{d.baseOrigin + "/sitemap.xml"}
+	if body, ok := d.fetch("/robots.txt", "robots.txt"); ok {
+		sitemaps = append(sitemaps, d.parseRobots(body)...)
+	}
+
+	// ---- sitemap.xml + indexes ----
+	fetched := make(map[string]bool)
+	for i := 0; i < len(sitemaps) && len(fetched) < maxSitemaps; i++ {
+		if fetched[sitemaps[i]] {
+			continue
+		}
+		fetched[sitemaps[i]] = true
+		if body, ok := d.fetch(sitemaps[i], "sitemap"); ok {
+			sitemaps = append(sitemaps, d.parseSitemap(sitemaps[i], body)...)
+		}
+	}
+
+	// ---- well-known files ----
+	for _, p := range wellKnownPaths {
+		source := "well-known"
+		if strings.Contains(p, "manifest") {
+			source = "manifest"
+		}
+		if strings.HasSuffix(p, "security.txt") {
+			source = "security.txt"
+		}
+
+		body, ok := d.fetch(p, source)
+		if !ok {
+			continue
+		}
+		switch source {
+		case "security.txt":
+			d.parseSecurityTxt(body)
+		default:
+			d.parseJSONURLs(body, source)
+		}
+	}
+
+	log.Printf("Discovery found %d seeds", len(d.seeds))
+	return d.seeds, d.entries, nil
+}
+
+// fetch GETs path (relative to the origin or absolute), records a HAR entry for it and returns the body on 2xx
+func (d *discoverer) fetch(p string, source string) ([]byte, bool) {
+	target := p
+	if strings.HasPrefix(p, "/") {
+		target = d.baseOrigin + p
+	}
+
+	resp, err := d.request.Get(target, pw.APIRequestContextGetOptions{
+		Headers:           d.headers.For(target),
+		Timeout:           pw.Float(d.navTimeout),
+		IgnoreHttpsErrors: pw.Bool(true),
+	})
+	if err != nil {
+		log.Printf("Discovery fetch of %s failed: %v", target, err)
+		return nil, false
+	}
+	defer resp.Dispose()
+	if !resp.Ok() {
+		return nil, false
+	}
+
+	body, err := resp.Body()
+	if err != nil {
+		return nil, false
+	}
+
+	// Gzipped sitemaps are served as is
+	if strings.HasSuffix(target, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, false
+		}
+		body, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	d.addEntry(target, source, false)
+	return body, true
+}
+
+// addEntry records rawURL as HAR entry, seed marks it as crawl seed as well
+func (d *discoverer) addEntry(rawURL string, source string, seed bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	u.Fragment = ""
+	abs := u.String()
+	if d.seen[abs] {
+		return
+	}
+	d.seen[abs] = true
+
+	entry := helper.NewHAREntry("GET", abs, nil, "", "")
+	entry.Source = source
+	d.entries = append(d.entries, entry)
+
+	if seed {
+		d.seeds = append(d.seeds, abs)
+	}
+}
+
+// parseRobots adds Allow/Disallow paths as seeds and returns the referenced sitemaps
+func (d *discoverer) parseRobots(body []byte) []string {
+	var sitemaps []string
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "sitemap":
+			sitemaps = append(sitemaps, val)
+		case "allow", "disallow":
+			// Cut wildcard patterns down to their static prefix
+			if i := strings.IndexAny(val, "*$"); i != -1 {
+				val = val[:i]
+			}
+			if val == "" || val == "/" || !strings.HasPrefix(val, "/") {
+				continue
+			}
+			d.addEntry(d.baseOrigin+val, "robots.txt", true)
+		}
+	}
+	return sitemaps
+}
+
+// parseSitemap adds all <loc> URLs as seeds and returns nested sitemaps of a sitemap index
+func (d *discoverer) parseSitemap(sitemapURL string, body []byte) []string {
+	var sm sitemap
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		log.Printf("Invalid sitemap %s: %v", sitemapURL, err)
+		return nil
+	}
+	for _, u := range sm.URLs {
+		d.addEntry(u.Loc, "sitemap", true)
+	}
+	var nested []string
+	for _, s := range sm.Sitemaps {
+		nested = append(nested, strings.TrimSpace(s.Loc))
+	}
+	return nested
+}
+
+// parseSecurityTxt adds URL valued fields (Policy, Acknowledgments, Hiring, ...) as seeds
+func (d *discoverer) parseSecurityTxt(body []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		_, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, "http://") || strings.HasPrefix(val, "https://") {
+			d.addEntry(val, "security.txt", true)
+		}
+	}
+}
+
+// parseJSONURLs walks a JSON document (manifest, openid-configuration, ...) and adds every
+// string that looks like an absolute URL or an absolute path as seed
+func (d *discoverer) parseJSONURLs(body []byte, source string) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+
+	var walk func(v interface{}, key string)
+	walk = func(v interface{}, key string) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, val := range t {
+				walk(val, k)
+			}
+		case []interface{}:
+			for _, val := range t {
+				walk(val, key)
+			}
+		case string:
+			// icons and screenshots are assets, not pages
+			if key == "src" {
+				return
+			}
+			switch {
+			case strings.HasPrefix(t, "http://") || strings.HasPrefix(t, "https://"):
+				d.addEntry(t, source, true)
+			case strings.HasPrefix(t, "/") && !strings.HasPrefix(t, "//"):
+				d.addEntry(d.baseOrigin+t, source, true)
+			}
+		}
+	}
+	walk(doc, "")
+}
@@ -0,0 +1,404 @@
+package headers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/scope"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Values shorter than this are too common to tell a leak from a coincidence
+const minSecretLength = 8
+
+// Set holds headers sent with every in-scope request and headers restricted to certain hosts.
+// A nil *Set has no headers.
+type Set struct {
+	Global map[string]string
+	Hosts  map[string]map[string]string // host glob -> headers
+	order  []string                     // host globs in the order they were added
+
+	scope *scope.Scope // global headers are only sent to in-scope hosts, nil means everywhere
+	mu    sync.Mutex
+	leaks []Leak
+}
+
+// Leak is a request that tried to carry one of our header values to a host it is not meant for
+type Leak struct {
+	URL    string
+	Where  string // header name, "url" or "body"
+	Header string // configured header whose value leaked
+}
+
+func New() *Set {
+	return &Set{
+		Global: make(map[string]string),
+		Hosts:  make(map[string]map[string]string),
+	}
+}
+
+// Clone returns an independent copy, so roles can add headers without touching the shared set
+func (s *Set) Clone() *Set {
+	c := New()
+	if s == nil {
+		return c
+	}
+	c.scope = s.scope
+	for k, v := range s.Global {
+		c.Global[k] = v
+	}
+	for _, glob := range s.order {
+		c.Hosts[glob] = make(map[string]string, len(s.Hosts[glob]))
+		for k, v := range s.Hosts[glob] {
+			c.Hosts[glob][k] = v
+		}
+		c.order = append(c.order, glob)
+	}
+	return c
+}
+
+// Header names that carry an identity besides Authorization and Cookie, e.g. X-Api-Key, X-Auth-Token, X-XSRF-TOKEN
+var credentialHints = []string{"auth", "token", "api-key", "apikey", "session", "csrf", "xsrf", "cookie"}
+
+// IsCredential reports whether a header name looks like it authenticates the request
+func IsCredential(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range credentialHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutCredentials returns a copy without credential headers, global or host-restricted,
+// and the names of the headers it dropped
+func (s *Set) WithoutCredentials() (*Set, []string) {
+	c := s.Clone()
+	var dropped []string
+	strip := func(m map[string]string) {
+		for k := range m {
+			if IsCredential(k) {
+				delete(m, k)
+				dropped = append(dropped, k)
+			}
+		}
+	}
+	strip(c.Global)
+	for _, glob := range c.order {
+		strip(c.Hosts[glob])
+	}
+	sort.Strings(dropped)
+	return c, dropped
+}
+
+// Add parses a single header. "Name: value" is sent everywhere,
+// "[host-glob] Name: value" only to matching hosts.
+func (s *Set) Add(raw string) error {
+	raw = strings.TrimSpace(raw)
+	host := ""
+	if strings.HasPrefix(raw, "[") {
+		end := strings.Index(raw, "]")
+		if end == -1 {
+			return fmt.Errorf("unterminated host in header %q", raw)
+		}
+		host = strings.ToLower(strings.TrimSpace(raw[1:end]))
+		raw = strings.TrimSpace(raw[end+1:])
+	}
+	return s.add(host, raw)
+}
+
+func (s *Set) add(host string, raw string) error {
+	name, value := helper.ParseHeaderFlag(raw)
+	if name == "" || !strings.Contains(raw, ":") {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", raw)
+	}
+
+	if host == "" {
+		set(s.Global, name, value)
+		return nil
+	}
+	if _, err := path.Match(host, ""); err != nil {
+		return fmt.Errorf("invalid host glob %q: %w", host, err)
+	}
+	if s.Hosts[host] == nil {
+		s.Hosts[host] = make(map[string]string)
+		s.order = append(s.order, host)
+	}
+	set(s.Hosts[host], name, value)
+	return nil
+}
+
+// set replaces a header case-insensitively, e.g. user-agent overrides User-Agent
+func set(m map[string]string, name, value string) {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			delete(m, k)
+		}
+	}
+	m[name] = value
+}
+
+// LoadFile reads one "Name: value" header per line. A "[host-glob]" line starts a section
+// whose headers are only sent to matching hosts, "[*]" switches back to global headers.
+// Empty lines and lines starting with # are ignored.
+func (s *Set) LoadFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lines, err := helper.ReadLines(f)
+	if err != nil {
+		return err
+	}
+
+	host := ""
+	for _, line := range lines {
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			host = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if host == "*" {
+				host = ""
+			}
+			continue
+		}
+		if err := s.add(host, line); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// Scoped returns a copy whose global headers are only sent to hosts in sc
+func (s *Set) Scoped(sc *scope.Scope) *Set {
+	c := s.Clone()
+	c.scope = sc
+	return c
+}
+
+// UserAgent returns the configured User-Agent, it is set on the context instead of per request
+func (s *Set) UserAgent() string {
+	if s == nil {
+		return ""
+	}
+	for k, v := range s.Global {
+		if strings.EqualFold(k, "User-Agent") {
+			return v
+		}
+	}
+	return ""
+}
+
+// For returns the headers for a request to rawURL, host specific headers override global ones.
+// Global headers are left out for hosts outside the scope.
+func (s *Set) For(rawURL string) map[string]string {
+	out := make(map[string]string)
+	if s == nil {
+		return out
+	}
+	if s.scope.InScope(rawURL) {
+		for k, v := range s.Global {
+			out[k] = v
+		}
+	}
+	for k, v := range s.hostHeaders(rawURL) {
+		out[k] = v
+	}
+	return out
+}
+
+// hostHeaders merges the headers of all host sections matching rawURL
+func (s *Set) hostHeaders(rawURL string) map[string]string {
+	if s == nil || len(s.order) == 0 {
+		return nil
+	}
+	host := strings.ToLower(helper.Hostname(rawURL))
+	var out map[string]string
+	for _, glob := range s.order {
+		if ok, _ := path.Match(glob, host); !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		for k, v := range s.Hosts[glob] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Install adds the headers of For to every request of browserCtx. Requests that carry one of
+// the configured values anywhere else (copied by the page into a third-party call) lose that
+// header and are recorded as leak. The User-Agent is set on the context and not handled here.
+func (s *Set) Install(browserCtx pw.BrowserContext) error {
+	if !s.hasRequestHeaders() {
+		return nil
+	}
+	secrets := s.secrets()
+	return browserCtx.Route("**/*", func(route pw.Route) {
+		req := route.Request()
+		u := req.URL()
+		extra := s.For(u)
+		for k := range extra {
+			if strings.EqualFold(k, "User-Agent") {
+				delete(extra, k)
+			}
+		}
+
+		// Playwright reports header names lower-cased
+		merged := req.Headers()
+		stripped := false
+		for name, value := range merged {
+			if header := leaked(value, secrets, extra); header != "" {
+				delete(merged, name)
+				stripped = true
+				s.addLeak(Leak{URL: u, Where: name, Header: header})
+			}
+		}
+		if header := leaked(u, secrets, extra); header != "" {
+			s.addLeak(Leak{URL: u, Where: "url", Header: header})
+		}
+		if body, err := req.PostData(); err == nil {
+			if header := leaked(body, secrets, extra); header != "" {
+				s.addLeak(Leak{URL: u, Where: "body", Header: header})
+			}
+		}
+
+		if len(extra) == 0 && !stripped {
+			route.Fallback()
+			return
+		}
+		for k, v := range extra {
+			merged[strings.ToLower(k)] = v
+		}
+		route.Fallback(pw.RouteFallbackOptions{Headers: merged})
+	})
+}
+
+// hasRequestHeaders reports whether any header besides the User-Agent is configured
+func (s *Set) hasRequestHeaders() bool {
+	if s == nil {
+		return false
+	}
+	check := func(m map[string]string) bool {
+		for k := range m {
+			if !strings.EqualFold(k, "User-Agent") {
+				return true
+			}
+		}
+		return false
+	}
+	if check(s.Global) {
+		return true
+	}
+	for _, glob := range s.order {
+		if check(s.Hosts[glob]) {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets maps every configured header value (and the token of "Scheme token" values) to its header name
+func (s *Set) secrets() map[string]string {
+	if s == nil {
+		return nil
+	}
+	out := make(map[string]string)
+	add := func(m map[string]string) {
+		for k, v := range m {
+			if strings.EqualFold(k, "User-Agent") {
+				continue
+			}
+			if len(v) >= minSecretLength {
+				out[v] = k
+			}
+			// Bearer/Basic tokens are often copied without their scheme
+			if _, token, ok := strings.Cut(v, " "); ok && len(token) >= minSecretLength {
+				out[token] = k
+			}
+		}
+	}
+	add(s.Global)
+	for _, glob := range s.order {
+		add(s.Hosts[glob])
+	}
+	return out
+}
+
+// leaked returns the header whose value shows up in text although the request is not meant to carry it
+func leaked(text string, secrets map[string]string, allowed map[string]string) string {
+	if text == "" {
+		return ""
+	}
+	for secret, header := range secrets {
+		if !strings.Contains(text, secret) {
+			continue
+		}
+		legit := false
+		for _, v := range allowed {
+			if strings.Contains(v, secret) {
+				legit = true
+				break
+			}
+		}
+		if !legit {
+			return header
+		}
+	}
+	return ""
+}
+
+func (s *Set) addLeak(l Leak) {
+	s.mu.Lock()
+	s.leaks = append(s.leaks, l)
+	s.mu.Unlock()
+}
+
+// ReportLeaks logs every request that tried to carry a configured header elsewhere
+// and marks the matching entries with the leaked headers
+func (s *Set) ReportLeaks(targetURL string, entries []*structs.HAREntry) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.leaks) == 0 {
+		return
+	}
+	sort.SliceStable(s.leaks, func(i, j int) bool { return s.leaks[i].URL < s.leaks[j].URL })
+	log.Printf("Headers: %d requests of %s tried to send configured headers to other hosts", len(s.leaks), targetURL)
+	byURL := make(map[string][]structs.HARLeak)
+	for _, l := range s.leaks {
+		action := "stripped"
+		if l.Where == "url" || l.Where == "body" {
+			action = "reported only"
+		}
+		log.Printf("  %s leaked via %s to %s (%s)", l.Header, l.Where, l.URL, action)
+		leak := structs.HARLeak{Header: l.Header, Where: l.Where}
+		dup := false
+		for _, seen := range byURL[l.URL] {
+			if seen == leak {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			byURL[l.URL] = append(byURL[l.URL], leak)
+		}
+	}
+	for _, e := range entries {
+		if leaks, ok := byURL[e.Request.URL]; ok {
+			e.Leaks = leaks
+		}
+	}
+}
@@ -1,22 +1,49 @@
 package helper
 
 import (
+	"bufio"
 	"encoding/json"
+	"io"
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/m-1tZ/reqtrack/pkg/structs"
 )
 
+// ParseHeaderFlag splits "Name: value" into its trimmed name and value
 func ParseHeaderFlag(h string) (string, string) {
 	for i := 0; i < len(h); i++ {
 		if h[i] == ':' {
-			return h[:i], h[i+1:]
+			return strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:])
 		}
 	}
-	return h, ""
+	return strings.TrimSpace(h), ""
+}
+
+// Hostname returns the host of rawURL without port, "" if it cannot be parsed
+func Hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// ReadLines returns all non-empty lines of r, lines starting with # are skipped
+func ReadLines(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
 }
 
 func SanitizeURL(raw, baseOrigin string) string {
@@ -245,6 +272,52 @@ func isLikelyFormURLEncoded(s string) bool {
 	return true
 }
 
+// NewHAREntry builds a request-only HAR entry, query params are taken from rawURL.
+// Header names are sorted so the output is stable.
+func NewHAREntry(method string, rawURL string, headers map[string]string, body string, mimeType string) *structs.HAREntry {
+	entry := &structs.HAREntry{
+		Request: structs.HARRequest{
+			Method:      strings.ToUpper(method),
+			URL:         rawURL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []structs.HARCookie{},
+			Headers:     []structs.HARNameValue{},
+			Query:       []structs.HARNameValue{},
+			PostData:    nil,
+			HeaderSize:  -1,
+			BodySize:    -1,
+		},
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry.Request.Headers = append(entry.Request.Headers, structs.HARNameValue{
+			Name:  name,
+			Value: headers[name],
+		})
+	}
+
+	for _, v := range ParseQueryParams(rawURL) {
+		entry.Request.Query = append(entry.Request.Query, structs.HARNameValue{
+			Name:  v.Name,
+			Value: v.Value,
+		})
+	}
+
+	if body != "" {
+		entry.Request.PostData = &structs.HARPostData{
+			MimeType: mimeType,
+			Text:     body,
+		}
+		entry.Request.BodySize = len(body)
+	}
+	return entry
+}
+
 // Merge scraped HAR entries with loaded HAR entries.
 // Scraped entries come without response objects, but that’s fine – we only keep Request anyway.
 func MergeHAREntries(base []*structs.HAREntry, scraped []*structs.HAREntry) []*structs.HAREntry {
@@ -255,6 +328,102 @@ func MergeHAREntries(base []*structs.HAREntry, scraped []*structs.HAREntry) []*s
 	return merged
 }
 
+// RequestKey identifies equal requests across entries, captures and roles
+type RequestKey struct {
+	Method      string
+	URL         string
+	Body        string
+	ContentType string
+}
+
+// EntryKey returns the key of an entry, relative URLs are resolved against baseOrigin
+func EntryKey(entry *structs.HAREntry, baseOrigin string) RequestKey {
+	req := &entry.Request
+
+	// Sanitize and normalize URLs (if variable ${} and then /<path>, just remove and pretend to target the root of the current origin) such as
+	// "url": "${f.getAuthServiceUrl()}/userinfo?origin=client",
+	// "url": "${(0,Vl.pN)(\"/_/api/commerce/prod\")}/shop/init-transaction/datatrans",
+	// "url": "/assets/files/lawyers/Anwaltsnetz_Tabelle.json",
+	// "url": "http://localhost:3000/external-executed",
+	// We always want absolute URLs, not relativ - thus get the targetURL and take the scheme + host and append relative
+	normalizedURL := SanitizeURL(req.URL, baseOrigin)
+	body := ""
+	if req.PostData != nil && req.PostData.Text != "" {
+		body = req.PostData.Text
+	}
+
+	contentType := ""
+	// extractContentType returns the POST Content-Type in this priority order:
+	// 1. HARRequest.PostData.MimeType
+	// 2. Header: Content-Type
+	if req.PostData != nil && req.PostData.MimeType != "" {
+		contentType = req.PostData.MimeType
+	} else {
+		for _, h := range req.Headers {
+			if strings.EqualFold(h.Name, "Content-Type") {
+				contentType = h.Value
+			}
+		}
+	}
+
+	// Tokens differ between sessions and roles while the request stays the same
+	if entry.CSRF != nil {
+		switch entry.CSRF.In {
+		case "query":
+			if i := strings.Index(normalizedURL, "?"); i != -1 {
+				normalizedURL = strings.TrimSuffix(normalizedURL[:i]+"?"+dropParam(normalizedURL[i+1:], entry.CSRF.Name), "?")
+			}
+		case "body":
+			body = dropField(body, entry.CSRF.Name)
+		}
+	}
+
+	return RequestKey{
+		Method:      req.Method,
+		URL:         normalizedURL,
+		Body:        body,
+		ContentType: contentType,
+	}
+}
+
+// dropParam removes the parameter name from a query string or form body
+func dropParam(query, name string) string {
+	var kept []string
+	for _, p := range strings.Split(query, "&") {
+		k, _, _ := strings.Cut(p, "=")
+		if n, err := url.QueryUnescape(k); err == nil && n == name {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return strings.Join(kept, "&")
+}
+
+// dropField removes the field name from a JSON object or form body
+func dropField(body, name string) string {
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(body), &obj) == nil {
+		if _, ok := obj[name]; !ok {
+			return body
+		}
+		delete(obj, name)
+		if b, err := json.Marshal(obj); err == nil {
+			return string(b)
+		}
+		return body
+	}
+	return dropParam(body, name)
+}
+
+// Origin returns scheme://host of rawURL
+func Origin(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
 // DeduplicateEntries removes duplicate HAR entries based on request key
 func DeduplicateHAREntries(
 	results []*structs.HAREntry,
@@ -262,73 +431,84 @@ func DeduplicateHAREntries(
 ) ([]*structs.HAREntry, error) {
 
 	// --- Deduplicate identical requests and remove empty URL ---
-	type RequestKey struct {
-		Method      string
-		URL         string
-		Body        string
-		ContentType string
-	}
-
-	parsedBase, err := url.Parse(targetURL)
+	baseOrigin, err := Origin(targetURL)
 	if err != nil {
 		return nil, err
 	}
-	baseOrigin := parsedBase.Scheme + "://" + parsedBase.Host
 
-	seen := make(map[RequestKey]bool)
+	seen := make(map[RequestKey]*structs.HAREntry)
 	var deduped []*structs.HAREntry
 
 	for _, entry := range results {
-		req := &entry.Request
-		if req.URL == "" {
+		if entry.Request.URL == "" {
 			continue
 		}
+		key := EntryKey(entry, baseOrigin)
 
-		// Sanitize and normalize URLs (if variable ${} and then /<path>, just remove and pretend to target the root of the current origin) such as
-		// "url": "${f.getAuthServiceUrl()}/userinfo?origin=client",
-		// "url": "${(0,Vl.pN)(\"/_/api/commerce/prod\")}/shop/init-transaction/datatrans",
-		// "url": "/assets/files/lawyers/Anwaltsnetz_Tabelle.json",
-		// "url": "http://localhost:3000/external-executed",
-		// We always want absolute URLs, not relativ - thus get the targetURL and take the scheme + host and append relative
-		normalizedURL := SanitizeURL(req.URL, baseOrigin)
-		body := ""
-		if req.PostData != nil && req.PostData.Text != "" {
-			body = req.PostData.Text
-		}
-
-		contentType := ""
-		// extractContentType returns the POST Content-Type in this priority order:
-		// 1. HARRequest.PostData.MimeType
-		// 2. Header: Content-Type
-		if req.PostData != nil && req.PostData.MimeType != "" {
-			contentType = req.PostData.MimeType
-		} else {
-			for _, h := range req.Headers {
-				if strings.EqualFold(h.Name, "Content-Type") {
-					contentType = h.Value
-				}
+		// Deduplicate, a successful response of a duplicate wins over a failed one
+		if kept, ok := seen[key]; ok {
+			if kept.Status == 0 || !Reachable(kept.Status) && Reachable(entry.Status) {
+				kept.Status = entry.Status
+			}
+			// A leak of any duplicate stays visible
+			if len(kept.Leaks) == 0 {
+				kept.Leaks = entry.Leaks
 			}
+			// Sent with a valid session once is enough
+			kept.SessionLost = kept.SessionLost && entry.SessionLost
+			mergeExtensions(kept, entry)
+			continue
 		}
+		seen[key] = entry
 
-		key := RequestKey{
-			Method:      req.Method,
-			URL:         normalizedURL,
-			Body:        body,
-			ContentType: contentType,
-		}
+		// Mutate HAR entry to sanitized URL, the key may lack the anti-CSRF parameter
+		entry.Request.URL = SanitizeURL(entry.Request.URL, baseOrigin)
 
-		// Deduplicate
-		if !seen[key] {
-			seen[key] = true
+		deduped = append(deduped, entry)
+	}
+
+	return deduped, nil
+}
 
-			// Mutate HAR entry to sanitized URL
-			req.URL = normalizedURL
+// mergeExtensions fills the extension fields kept lacks from its duplicate dup,
+// e.g. the _initiator of a captured request whose static twin was found first
+func mergeExtensions(kept, dup *structs.HAREntry) {
+	if kept.Source == "" {
+		kept.Source = dup.Source
+	}
+	if kept.Frame == "" {
+		kept.Frame = dup.Frame
+	}
+	if kept.Initiator == nil {
+		kept.Initiator = dup.Initiator
+	}
+	if kept.Client == "" {
+		kept.Client = dup.Client
+	}
+	if kept.CSRF == nil {
+		kept.CSRF = dup.CSRF
+	}
+	if kept.ResourceType == "" {
+		kept.ResourceType = dup.ResourceType
+	}
+	if len(kept.WebSocketMessages) == 0 {
+		kept.WebSocketMessages = dup.WebSocketMessages
+	}
+}
 
-			deduped = append(deduped, entry)
+// HasHeader reports whether headers contain name, compared case-insensitively
+func HasHeader(headers []structs.HARNameValue, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
 		}
 	}
+	return false
+}
 
-	return deduped, nil
+// Reachable reports whether a response status means the request was served
+func Reachable(status int) bool {
+	return status >= 200 && status < 400
 }
 
 func LoadHAREntriesStreaming(path string) ([]*structs.HAREntry, error) {
@@ -360,11 +540,21 @@ func LoadHAREntriesStreaming(path string) ([]*structs.HAREntry, error) {
 	}
 
 	// Stream each entry
+	// Only the status of the response is kept
+	type recorded struct {
+		structs.HAREntry
+		Response struct {
+			Status int `json:"status"`
+		} `json:"response"`
+	}
+
 	for dec.More() {
-		var e structs.HAREntry
-		if err := dec.Decode(&e); err != nil {
+		var r recorded
+		if err := dec.Decode(&r); err != nil {
 			return nil, err
 		}
+		e := r.HAREntry
+		e.Status = r.Response.Status
 		entries = append(entries, &e)
 	}
 
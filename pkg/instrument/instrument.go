@@ -0,0 +1,270 @@
+package instrument
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Single call of a wrapped network primitive, sent by the init script
+type record struct {
+	ID          string            `json:"id"`
+	Type        string            `json:"type"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	ContentType string            `json:"contentType"`
+	Stack       string            `json:"stack"`
+	Error       string            `json:"error"`
+}
+
+// Recorder collects every call of fetch, XMLHttpRequest, sendBeacon, WebSocket, EventSource and Worker
+// made inside a BrowserContext, including calls that fail or are blocked by CORS.
+type Recorder struct {
+	mu      sync.Mutex
+	records []*record
+	byID    map[string]*record
+}
+
+func New() *Recorder {
+	return &Recorder{byID: make(map[string]*record)}
+}
+
+// Install exposes the recording binding and injects the wrappers into every frame of browserCtx.
+// Must be called before the first page is opened.
+func (r *Recorder) Install(browserCtx pw.BrowserContext) error {
+	if r == nil {
+		return nil
+	}
+	err := browserCtx.ExposeFunction("__reqtrackRecord", func(args ...interface{}) interface{} {
+		if len(args) == 0 {
+			return nil
+		}
+		raw, ok := args[0].(string)
+		if !ok {
+			return nil
+		}
+		r.add(raw)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return browserCtx.AddInitScript(pw.Script{Content: pw.String(getInstrumentJS())})
+}
+
+// add stores a record, or attaches the error of a follow-up message to the record it belongs to
+func (r *Recorder) add(raw string) {
+	var rec record
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		log.Printf("Instrumentation: invalid record: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prev, ok := r.byID[rec.ID]; ok {
+		if rec.Error != "" {
+			prev.Error = rec.Error
+		}
+		return
+	}
+	if rec.URL == "" {
+		return
+	}
+	r.byID[rec.ID] = &rec
+	r.records = append(r.records, &rec)
+}
+
+// Annotate attaches initiator metadata to the HAR entries the browser recorded and returns
+// HAR entries for all calls no recorded request covers (failed, CORS-blocked, aborted, ...).
+func (r *Recorder) Annotate(entries []*structs.HAREntry) []*structs.HAREntry {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byKey := make(map[string]*structs.HAREntry, len(entries))
+	for _, e := range entries {
+		key := strings.ToUpper(e.Request.Method) + " " + e.Request.URL
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = e
+		}
+	}
+
+	var missing []*structs.HAREntry
+	seen := make(map[string]bool)
+	for _, rec := range r.records {
+		method := strings.ToUpper(rec.Method)
+		if method == "" {
+			method = "GET"
+		}
+		initiator := &structs.HARInitiator{
+			Type:  rec.Type,
+			Stack: rec.Stack,
+			Error: rec.Error,
+		}
+
+		key := method + " " + rec.URL
+		if e, ok := byKey[key]; ok {
+			if e.Initiator == nil {
+				e.Initiator = initiator
+			}
+			continue
+		}
+		if seen[key+"\x00"+rec.Body] {
+			continue
+		}
+		seen[key+"\x00"+rec.Body] = true
+
+		ctype := rec.ContentType
+		for k, v := range rec.Headers {
+			if strings.EqualFold(k, "Content-Type") {
+				ctype = v
+			}
+		}
+		entry := helper.NewHAREntry(method, rec.URL, rec.Headers, rec.Body, ctype)
+		entry.Source = "instrumentation"
+		entry.Initiator = initiator
+		missing = append(missing, entry)
+	}
+
+	if len(missing) > 0 {
+		log.Printf("Instrumentation: %d calls did not show up in the browser HAR", len(missing))
+	}
+	return missing
+}
+
+func getInstrumentJS() string {
+	return `(() => {
+		if (window.__reqtrackInstrumented) return;
+		Object.defineProperty(window, '__reqtrackInstrumented', { value: true, enumerable: false });
+
+		const prefix = Math.random().toString(36).slice(2);
+		let seq = 0;
+		const nextID = () => prefix + '-' + (++seq);
+
+		const send = (rec) => {
+			try { window.__reqtrackRecord(JSON.stringify(rec)); } catch (e) {}
+		};
+		// Drop the wrapper frames, keep the application's call site
+		const stack = () => (new Error().stack || '').split('\n').slice(3).map(l => l.trim()).join('\n');
+		const abs = (u) => {
+			try { return new URL(String(u), location.href).href; } catch (e) { return String(u); }
+		};
+		const headersObj = (h) => {
+			const o = {};
+			if (!h) return o;
+			try { new Headers(h).forEach((v, k) => { o[k] = v; }); } catch (e) {}
+			return o;
+		};
+		const bodyText = (b) => {
+			if (b === undefined || b === null) return '';
+			if (typeof b === 'string') return b;
+			if (b instanceof URLSearchParams) return b.toString();
+			if (b instanceof FormData) {
+				const parts = [];
+				for (const [k, v] of b) {
+					parts.push(encodeURIComponent(k) + '=' + encodeURIComponent(typeof v === 'string' ? v : '[file ' + (v.name || '') + ']'));
+				}
+				return parts.join('&');
+			}
+			if (b instanceof Blob) return '[blob ' + (b.type || '') + ' ' + b.size + ' bytes]';
+			if (b instanceof ArrayBuffer || ArrayBuffer.isView(b)) return '[binary ' + b.byteLength + ' bytes]';
+			try { return JSON.stringify(b); } catch (e) { return String(b); }
+		};
+		const bodyType = (b) => {
+			if (b instanceof URLSearchParams) return 'application/x-www-form-urlencoded';
+			if (b instanceof FormData) return 'multipart/form-data';
+			if (b instanceof Blob) return b.type || 'application/octet-stream';
+			if (b instanceof ArrayBuffer || ArrayBuffer.isView(b)) return 'application/octet-stream';
+			if (typeof b === 'string') return 'text/plain;charset=UTF-8';
+			return '';
+		};
+
+		// ---- fetch ----
+		const origFetch = window.fetch;
+		if (origFetch) {
+			window.fetch = function(input, init) {
+				const id = nextID();
+				try {
+					const req = input instanceof Request ? input : null;
+					const opts = init || {};
+					send({
+						id, type: 'fetch',
+						method: String(opts.method || (req && req.method) || 'GET'),
+						url: abs(req ? req.url : input),
+						headers: Object.assign(headersObj(req && req.headers), headersObj(opts.headers)),
+						body: bodyText(opts.body),
+						contentType: bodyType(opts.body),
+						stack: stack(),
+					});
+				} catch (e) {}
+				const p = origFetch.apply(this, arguments);
+				p.catch(err => send({ id, error: String(err) }));
+				return p;
+			};
+		}
+
+		// ---- XMLHttpRequest ----
+		const XHR = window.XMLHttpRequest && window.XMLHttpRequest.prototype;
+		if (XHR) {
+			const open = XHR.open, setHeader = XHR.setRequestHeader, xsend = XHR.send;
+			XHR.open = function(method, url) {
+				this.__reqtrack = { method: String(method || 'GET'), url: abs(url), headers: {} };
+				return open.apply(this, arguments);
+			};
+			XHR.setRequestHeader = function(name, value) {
+				if (this.__reqtrack) this.__reqtrack.headers[name] = String(value);
+				return setHeader.apply(this, arguments);
+			};
+			XHR.send = function(body) {
+				if (this.__reqtrack) {
+					const id = nextID();
+					send(Object.assign({ id, type: 'xhr', body: bodyText(body), contentType: bodyType(body), stack: stack() }, this.__reqtrack));
+					this.addEventListener('error', () => send({ id, error: 'network error' }));
+					this.addEventListener('abort', () => send({ id, error: 'aborted' }));
+				}
+				return xsend.apply(this, arguments);
+			};
+		}
+
+		// ---- navigator.sendBeacon ----
+		if (navigator.sendBeacon) {
+			const beacon = navigator.sendBeacon;
+			navigator.sendBeacon = function(url, data) {
+				const id = nextID();
+				send({ id, type: 'beacon', method: 'POST', url: abs(url), body: bodyText(data), contentType: bodyType(data), stack: stack() });
+				const ok = beacon.apply(navigator, arguments);
+				if (!ok) send({ id, error: 'beacon rejected' });
+				return ok;
+			};
+		}
+
+		// ---- WebSocket, EventSource, Worker, SharedWorker ----
+		for (const [name, type] of [['WebSocket', 'websocket'], ['EventSource', 'eventsource'], ['Worker', 'worker'], ['SharedWorker', 'worker']]) {
+			const Orig = window[name];
+			if (!Orig) continue;
+			window[name] = new Proxy(Orig, {
+				construct(target, args, newTarget) {
+					const id = nextID();
+					send({ id, type, method: 'GET', url: abs(args[0]), stack: stack() });
+					try {
+						return Reflect.construct(target, args, newTarget);
+					} catch (e) {
+						send({ id, error: String(e) });
+						throw e;
+					}
+				},
+			});
+		}
+	})();`
+}
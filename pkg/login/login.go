@@ -0,0 +1,199 @@
+package login
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	pw "github.com/playwright-community/playwright-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Step is a single action of a login recipe, exactly one action field is set
+type Step struct {
+	Goto         string  `yaml:"goto"`
+	Fill         string  `yaml:"fill"` // selector, filled with Value
+	Value        string  `yaml:"value"`
+	Click        string  `yaml:"click"`
+	TOTP         string  `yaml:"totp"` // selector, filled with a TOTP code generated from Secret
+	Secret       string  `yaml:"secret"`
+	WaitURL      string  `yaml:"wait_url"` // Playwright glob
+	WaitSelector string  `yaml:"wait_selector"`
+	Wait         float64 `yaml:"wait"` // milliseconds
+}
+
+// Check decides whether the login succeeded, every configured condition must hold
+type Check struct {
+	URL         string `yaml:"url"` // regular expression matched against the final page URL
+	Selector    string `yaml:"selector"`
+	NotSelector string `yaml:"not_selector"`
+	Cookie      string `yaml:"cookie"`
+}
+
+// Recipe is a declarative login flow, loaded from YAML or JSON
+type Recipe struct {
+	Steps   []Step `yaml:"steps"`
+	Success Check  `yaml:"success"`
+
+	successURL *regexp.Regexp
+}
+
+// ${NAME} references to environment variables, so secrets stay out of the recipe
+var envRef = regexp.MustCompile(`\$\{(\w+)\}`)
+
+func expand(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(m string) string {
+		return os.Getenv(m[2 : len(m)-1])
+	})
+}
+
+// Load reads and validates a recipe. JSON is valid YAML, so both formats are accepted.
+func Load(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Recipe
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(r.Steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	for i, s := range r.Steps {
+		if n := s.actions(); n != 1 {
+			return nil, fmt.Errorf("%s: step %d has %d actions, expected exactly one", path, i+1, n)
+		}
+		if s.TOTP != "" {
+			if _, err := totp(expand(s.Secret)); err != nil {
+				return nil, fmt.Errorf("%s: step %d: %w", path, i+1, err)
+			}
+		}
+	}
+	if r.Success.URL != "" {
+		if r.successURL, err = regexp.Compile(r.Success.URL); err != nil {
+			return nil, fmt.Errorf("%s: invalid success url: %w", path, err)
+		}
+	}
+	return &r, nil
+}
+
+func (s Step) actions() int {
+	n := 0
+	for _, set := range []bool{s.Goto != "", s.Fill != "", s.Click != "", s.TOTP != "", s.WaitURL != "", s.WaitSelector != "", s.Wait > 0} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Run executes the recipe in a new page of browserCtx and verifies the success check.
+// The page bypasses the routes of the context (scope, safety guard), so login requests to
+// an SSO host or a POST of the login form are never blocked. Cookies and storage end up in browserCtx.
+func (r *Recipe) Run(browserCtx pw.BrowserContext, navTimeout float64, hdr *headers.Set) error {
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return err
+	}
+	defer page.Close()
+	page.SetDefaultTimeout(navTimeout)
+
+	err = page.Route("**/*", func(route pw.Route) {
+		req := route.Request()
+		extra := hdr.For(req.URL())
+		if len(extra) == 0 {
+			route.Continue()
+			return
+		}
+		merged := req.Headers()
+		for k, v := range extra {
+			merged[strings.ToLower(k)] = v
+		}
+		route.Continue(pw.RouteContinueOptions{Headers: merged})
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, s := range r.Steps {
+		if err := s.run(page); err != nil {
+			return fmt.Errorf("login step %d: %w", i+1, err)
+		}
+	}
+
+	page.WaitForLoadState(pw.PageWaitForLoadStateOptions{
+		State: pw.LoadStateNetworkidle,
+	})
+	if err := r.verify(browserCtx, page); err != nil {
+		return err
+	}
+	log.Printf("Login succeeded, landed on %s", page.URL())
+	return nil
+}
+
+func (s Step) run(page pw.Page) error {
+	switch {
+	case s.Goto != "":
+		_, err := page.Goto(expand(s.Goto), pw.PageGotoOptions{
+			WaitUntil: pw.WaitUntilStateNetworkidle,
+		})
+		return err
+	case s.Fill != "":
+		return page.Locator(s.Fill).First().Fill(expand(s.Value))
+	case s.TOTP != "":
+		code, err := totp(expand(s.Secret))
+		if err != nil {
+			return err
+		}
+		return page.Locator(s.TOTP).First().Fill(code)
+	case s.Click != "":
+		return page.Locator(s.Click).First().Click()
+	case s.WaitURL != "":
+		return page.WaitForURL(s.WaitURL)
+	case s.WaitSelector != "":
+		return page.Locator(s.WaitSelector).First().WaitFor()
+	case s.Wait > 0:
+		page.WaitForTimeout(s.Wait)
+	}
+	return nil
+}
+
+// verify checks the success conditions on the page the recipe ended on
+func (r *Recipe) verify(browserCtx pw.BrowserContext, page pw.Page) error {
+	c := r.Success
+	if r.successURL != nil && !r.successURL.MatchString(page.URL()) {
+		return fmt.Errorf("login check failed: %s does not match %q", page.URL(), c.URL)
+	}
+	if c.Selector != "" {
+		if n, err := page.Locator(c.Selector).Count(); err != nil || n == 0 {
+			return fmt.Errorf("login check failed: %q not found", c.Selector)
+		}
+	}
+	if c.NotSelector != "" {
+		if n, err := page.Locator(c.NotSelector).Count(); err == nil && n > 0 {
+			return fmt.Errorf("login check failed: %q still present", c.NotSelector)
+		}
+	}
+	if c.Cookie != "" {
+		jar, err := browserCtx.Cookies()
+		if err != nil {
+			return err
+		}
+		for _, ck := range jar {
+			if ck.Name == c.Cookie {
+				return nil
+			}
+		}
+		return fmt.Errorf("login check failed: cookie %q not set", c.Cookie)
+	}
+	return nil
+}
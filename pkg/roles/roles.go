@@ -0,0 +1,265 @@
+package roles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/cookies"
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/login"
+	"github.com/m-1tZ/reqtrack/pkg/runner"
+	pw "github.com/playwright-community/playwright-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is one identity the capture runs under. A role without any credentials is anonymous.
+// Paths are relative to the roles file.
+type Role struct {
+	Name         string   `yaml:"name"`
+	Headers      []string `yaml:"headers"`
+	HeaderFile   string   `yaml:"header_file"`
+	Cookies      string   `yaml:"cookies"` // Netscape or JSON cookie file
+	Cookie       string   `yaml:"cookie"`  // raw cookie header
+	StorageState string   `yaml:"storage_state"`
+	Login        string   `yaml:"login"` // login recipe
+
+	hdr    *headers.Set
+	jar    []pw.OptionalCookie
+	recipe *login.Recipe
+}
+
+// Role names end up in file names
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Load reads the roles of a YAML or JSON file and resolves their credentials.
+// Every role starts from base without its credential headers, so a role only
+// authenticates with what it brings. The role's headers are added on top.
+func Load(path string, base *headers.Set) ([]*Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Roles []*Role `yaml:"roles"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(file.Roles) < 2 {
+		return nil, fmt.Errorf("%s: at least two roles are needed for a diff", path)
+	}
+
+	dir := filepath.Dir(path)
+	rel := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	shared, dropped := base.WithoutCredentials()
+	if len(dropped) > 0 {
+		log.Printf("Roles: global headers %s are credentials and not sent for any role, add them to the roles that need them", strings.Join(dropped, ", "))
+	}
+
+	names := make(map[string]bool)
+	for _, r := range file.Roles {
+		if !validName.MatchString(r.Name) {
+			return nil, fmt.Errorf("%s: invalid role name %q", path, r.Name)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("%s: duplicate role %q", path, r.Name)
+		}
+		names[r.Name] = true
+
+		r.hdr = shared.Clone()
+		if r.HeaderFile != "" {
+			if err := r.hdr.LoadFile(rel(r.HeaderFile)); err != nil {
+				return nil, fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+		for _, h := range r.Headers {
+			if err := r.hdr.Add(h); err != nil {
+				return nil, fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+		if r.Cookies != "" {
+			if r.jar, err = cookies.Load(rel(r.Cookies)); err != nil {
+				return nil, fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+		if r.StorageState != "" {
+			r.StorageState = rel(r.StorageState)
+			if _, err := os.Stat(r.StorageState); err != nil {
+				return nil, fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+		if r.Login != "" {
+			if r.recipe, err = login.Load(rel(r.Login)); err != nil {
+				return nil, fmt.Errorf("role %s: %w", r.Name, err)
+			}
+		}
+	}
+	return file.Roles, nil
+}
+
+// Options returns base with the identity replaced by the role's credentials
+func (r *Role) Options(base runner.Options) runner.Options {
+	opts := base
+	opts.Headers = r.hdr
+	opts.Cookies = r.jar
+	opts.CookieHeader = r.Cookie
+	opts.StorageState = r.StorageState
+	opts.Login = r.recipe
+	return opts
+}
+
+// Run captures targetURL once per role. Roles run one after another, so they
+// do not interfere with each other's sessions on the server.
+func Run(browser pw.Browser, targetURL string, base runner.Options, roles []*Role) (map[string]*runner.Result, *Report, error) {
+	results := make(map[string]*runner.Result, len(roles))
+	for _, r := range roles {
+		log.Printf("Capturing %s as %s", targetURL, r.Name)
+		res, err := runner.Run(browser, targetURL, r.Options(base))
+		if err != nil {
+			return nil, nil, fmt.Errorf("role %s: %w", r.Name, err)
+		}
+		results[r.Name] = res
+	}
+
+	report, err := Diff(targetURL, roles, results)
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, report, nil
+}
+
+// ---- DIFF ----
+
+// Endpoint is a request seen under at least one role
+type Endpoint struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	ContentType string         `json:"contentType,omitempty"`
+	Body        string         `json:"body,omitempty"`
+	Referenced  []string       `json:"referenced"`       // roles whose capture contains the request
+	Reachable   []string       `json:"reachable"`        // roles that got a 2xx/3xx response
+	Status      map[string]int `json:"status,omitempty"` // role -> response status
+}
+
+// RoleDiff lists the endpoints no other role has, as "METHOD URL"
+type RoleDiff struct {
+	OnlyReachable  []string `json:"onlyReachable"`
+	OnlyReferenced []string `json:"onlyReferenced"`
+}
+
+// Report is the differential result of one target
+type Report struct {
+	Target    string               `json:"target"`
+	Roles     map[string]*RoleDiff `json:"roles"`
+	Endpoints []*Endpoint          `json:"endpoints"`
+}
+
+// Diff lines up the entries of all roles by their deduplication key
+func Diff(targetURL string, roles []*Role, results map[string]*runner.Result) (*Report, error) {
+	baseOrigin, err := helper.Origin(targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[helper.RequestKey]*Endpoint)
+	var order []helper.RequestKey
+	for _, r := range roles {
+		res := results[r.Name]
+		if res == nil {
+			continue
+		}
+		for _, e := range res.Entries {
+			key := helper.EntryKey(e, baseOrigin)
+			ep, ok := byKey[key]
+			if !ok {
+				ep = &Endpoint{
+					Method:      key.Method,
+					URL:         key.URL,
+					ContentType: key.ContentType,
+					Body:        key.Body,
+					Referenced:  []string{},
+					Reachable:   []string{},
+				}
+				byKey[key] = ep
+				order = append(order, key)
+			}
+			if !slices.Contains(ep.Referenced, r.Name) {
+				ep.Referenced = append(ep.Referenced, r.Name)
+			}
+			if e.Status != 0 {
+				if ep.Status == nil {
+					ep.Status = make(map[string]int)
+				}
+				if prev, ok := ep.Status[r.Name]; !ok || (!helper.Reachable(prev) && helper.Reachable(e.Status)) {
+					ep.Status[r.Name] = e.Status
+				}
+			}
+			if helper.Reachable(e.Status) && !slices.Contains(ep.Reachable, r.Name) {
+				ep.Reachable = append(ep.Reachable, r.Name)
+			}
+		}
+	}
+
+	report := &Report{
+		Target: targetURL,
+		Roles:  make(map[string]*RoleDiff, len(roles)),
+	}
+	for _, r := range roles {
+		report.Roles[r.Name] = &RoleDiff{OnlyReachable: []string{}, OnlyReferenced: []string{}}
+	}
+	for _, key := range order {
+		ep := byKey[key]
+		report.Endpoints = append(report.Endpoints, ep)
+		name := ep.Method + " " + ep.URL
+		if len(ep.Referenced) == 1 {
+			d := report.Roles[ep.Referenced[0]]
+			d.OnlyReferenced = appendUnique(d.OnlyReferenced, name)
+		}
+		if len(ep.Reachable) == 1 {
+			d := report.Roles[ep.Reachable[0]]
+			d.OnlyReachable = appendUnique(d.OnlyReachable, name)
+		}
+	}
+	for _, d := range report.Roles {
+		sort.Strings(d.OnlyReachable)
+		sort.Strings(d.OnlyReferenced)
+	}
+	return report, nil
+}
+
+// WriteReports writes the reports of all targets as JSON to path
+func WriteReports(path string, reports []*Report) error {
+	if reports == nil {
+		reports = []*Report{}
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Endpoints differing only in body share a name
+func appendUnique(list []string, v string) []string {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
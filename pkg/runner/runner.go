@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/m-1tZ/reqtrack/pkg/cookies"
+	"github.com/m-1tZ/reqtrack/pkg/crawl"
+	"github.com/m-1tZ/reqtrack/pkg/credentials"
+	"github.com/m-1tZ/reqtrack/pkg/csrf"
+	"github.com/m-1tZ/reqtrack/pkg/discover"
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/instrument"
+	"github.com/m-1tZ/reqtrack/pkg/login"
+	"github.com/m-1tZ/reqtrack/pkg/safety"
+	"github.com/m-1tZ/reqtrack/pkg/scope"
+	"github.com/m-1tZ/reqtrack/pkg/session"
+	"github.com/m-1tZ/reqtrack/pkg/sockets"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	"github.com/m-1tZ/reqtrack/pkg/synth"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Options shared by all targets of a run
+type Options struct {
+	Headers      *headers.Set
+	NavTimeout   float64 // seconds
+	ParseTimeout float64 // seconds
+	MaxDepth     int
+	MaxPages     int
+	Discover     bool
+	Scope        *scope.Scope // shared rules, see scope.ForTarget
+	Safety       safety.Config
+	Instrument   bool // wrap the network primitives to record initiators and failed calls
+	Cookies      []pw.OptionalCookie
+	CookieHeader string // raw "a=b; c=d", bound to the origin of each target
+	StorageState string // Playwright storage-state file loaded into every context
+	Login        *login.Recipe
+	Session      session.Config // logout signals, the session is restored with Login or StorageState
+
+	// Basic/Digest auth and mutual TLS, also used by the APIRequestContext of discovery and scraping
+	HttpCredentials    *pw.HttpCredentials
+	ClientCertificates []pw.ClientCertificate
+}
+
+// Result of a single target
+type Result struct {
+	Entries []*structs.HAREntry
+	Sockets []*sockets.Session
+	Cookies []pw.Cookie // final cookie jar
+	State   *pw.StorageState
+}
+
+// Run processes a single target in its own BrowserContext of the shared browser:
+// discovery, crawl (capture + scrape), HAR loading, merge, dedupe and scope filtering.
+func Run(
+	browser pw.Browser,
+	targetURL string,
+	opts Options,
+) (*Result, error) {
+
+	sc, err := opts.Scope.ForTarget(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	// Global headers only go to in-scope hosts of this target
+	hdr := opts.Headers.Scoped(sc)
+	guard, err := safety.New(opts.Safety)
+	if err != nil {
+		return nil, err
+	}
+	// Credentials without an origin only answer challenges of the target
+	httpCreds, err := credentials.ForTarget(opts.HttpCredentials, targetURL)
+	if err != nil {
+		return nil, err
+	}
+	navTimeoutMs := float64((time.Duration(opts.NavTimeout) * time.Second) / time.Millisecond)
+
+	// Every context records into its own temporary HAR, the minified result is written by the caller
+	harFile, err := os.CreateTemp("", "reqtrack-*.har")
+	if err != nil {
+		return nil, err
+	}
+	harPath := harFile.Name()
+	harFile.Close()
+	defer os.Remove(harPath)
+
+	// ---- Browser Context with HAR ----
+	ctxOpts := pw.BrowserNewContextOptions{
+		RecordHarPath:     pw.String(harPath),
+		RecordHarMode:     pw.HarModeFull,
+		IgnoreHttpsErrors: pw.Bool(true),
+		// browserCtx.Request() inherits both, so fetched scripts and discovery requests authenticate too
+		HttpCredentials:    httpCreds,
+		ClientCertificates: opts.ClientCertificates,
+	}
+	if ua := hdr.UserAgent(); ua != "" {
+		ctxOpts.UserAgent = pw.String(ua)
+	}
+	if opts.StorageState != "" {
+		ctxOpts.StorageStatePath = pw.String(opts.StorageState)
+	}
+	browserCtx, err := browser.NewContext(ctxOpts)
+	if err != nil {
+		return nil, err
+	}
+	// Closing twice is harmless, this only covers the error paths
+	defer browserCtx.Close()
+
+	// browserCtx.SetDefaultTimeout(float64((2 * time.Second) / time.Millisecond)) // 30s
+	browserCtx.SetDefaultNavigationTimeout(navTimeoutMs)
+
+	if err = sc.Enforce(browserCtx); err != nil {
+		return nil, err
+	}
+	if err = guard.Install(browserCtx); err != nil {
+		return nil, err
+	}
+	if err = hdr.Install(browserCtx); err != nil {
+		return nil, err
+	}
+
+	var recorder *instrument.Recorder
+	if opts.Instrument {
+		recorder = instrument.New()
+		if err = recorder.Install(browserCtx); err != nil {
+			return nil, err
+		}
+	}
+
+	socketRecorder := sockets.New()
+	if err = socketRecorder.Install(browserCtx); err != nil {
+		return nil, err
+	}
+
+	tokens := csrf.New()
+	if err = tokens.Install(browserCtx); err != nil {
+		return nil, err
+	}
+
+	// ---- COOKIES (before the first request) ----
+	jar := append([]pw.OptionalCookie{}, opts.Cookies...)
+	if opts.CookieHeader != "" {
+		jar = append(jar, cookies.ParseHeader(opts.CookieHeader, targetURL)...)
+	}
+	if len(jar) > 0 {
+		if err = browserCtx.AddCookies(jar); err != nil {
+			return nil, fmt.Errorf("adding cookies failed: %w", err)
+		}
+	}
+
+	// ---- LOGIN (recipe runs before any capture) ----
+	if opts.Login != nil {
+		if err = opts.Login.Run(browserCtx, navTimeoutMs, hdr); err != nil {
+			return nil, fmt.Errorf("login for %s failed: %w", targetURL, err)
+		}
+	}
+
+	var monitor *session.Monitor
+	if opts.Session.Enabled() {
+		monitor, err = session.New(opts.Session, session.Recovery{
+			Login:        opts.Login,
+			StorageState: opts.StorageState,
+			NavTimeout:   navTimeoutMs,
+			Headers:      hdr,
+			Cookies:      jar,
+		}, targetURL)
+		if err != nil {
+			return nil, err
+		}
+		if err = monitor.Install(browserCtx); err != nil {
+			return nil, err
+		}
+	}
+
+	// ---- DISCOVERY (robots.txt, sitemaps, well-known files) ----
+	seeds := []string{targetURL}
+	var discoveredEntries []*structs.HAREntry
+	if opts.Discover {
+		found, entries, err := discover.Discover(browserCtx, targetURL, navTimeoutMs, hdr)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range found {
+			if sc.InScope(s) {
+				seeds = append(seeds, s)
+			}
+		}
+		discoveredEntries = entries
+	}
+
+	// ---- CRAWL (capture + scrape on every page) ----
+	crawled, err := crawl.Crawl(browserCtx, seeds, crawl.Options{
+		MaxDepth:     opts.MaxDepth,
+		MaxPages:     opts.MaxPages,
+		NavTimeout:   navTimeoutMs,
+		ParseTimeout: opts.ParseTimeout,
+		Scope:        sc,
+		Guard:        guard,
+		Session:      monitor,
+		CSRF:         tokens,
+		Headers:      hdr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Scrape results can only be integrated if .har file was written
+	// loop over har file and remove response objects. add scraped objects and unique the requests
+
+	tokens.CollectCookies(browserCtx)
+	finalJar, err := browserCtx.Cookies()
+	if err != nil {
+		log.Printf("Reading cookie jar of %s failed: %v", targetURL, err)
+	}
+	state, err := browserCtx.StorageState()
+	if err != nil {
+		log.Printf("Reading storage state of %s failed: %v", targetURL, err)
+	}
+
+	// ---- Close so HAR gets written ----
+	if err = browserCtx.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Done with %s. HAR will now be deduplicated and minified", targetURL)
+	entries, err := helper.LoadHAREntriesStreaming(harPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attribute requests of child frames before URLs get normalized
+	crawled.AttributeFrames(entries)
+	monitor.Annotate(entries)
+	hdr.ReportLeaks(targetURL, entries)
+
+	// Scraped entries get the headers and cookies the browser sent, so they can be replayed
+	synth.Apply(crawled.Entries, entries, targetURL)
+
+	// Requests that have to be replayed get the anti-CSRF token
+	blocked := guard.Report(targetURL)
+	unsent := recorder.Annotate(entries)
+	pending := helper.MergeHAREntries(crawled.Entries, blocked)
+	tokens.Apply(entries, helper.MergeHAREntries(pending, unsent))
+
+	// ---- MERGE SCRAPED + HAR LOADED ----
+	merged := helper.MergeHAREntries(entries, crawled.Entries)
+	merged = helper.MergeHAREntries(merged, discoveredEntries)
+	merged = helper.MergeHAREntries(merged, blocked)
+	merged = helper.MergeHAREntries(merged, unsent)
+	merged = helper.MergeHAREntries(merged, socketRecorder.Attach(entries))
+
+	deduped, err := helper.DeduplicateHAREntries(merged, targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error in DeduplicateHAREntries: %w", err)
+	}
+
+	// ---- SCOPE FILTER ----
+	return &Result{
+		Entries: sc.FilterEntries(deduped),
+		Sockets: socketRecorder.Sessions(),
+		Cookies: finalJar,
+		State:   state,
+	}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// OutputName turns a target URL into a HAR file name, e.g. https://example.com:8443/app -> example.com_8443_app.har
+func OutputName(targetURL string) string {
+	name := targetURL
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "target"
+	}
+	return name + ".har"
+}
+
+// OutputNames returns the HAR file names of targets in order. The scheme and replaced characters
+// are lost in OutputName, so a name already taken gets a _2, _3, ... suffix.
+func OutputNames(targets []string) []string {
+	names := make([]string, len(targets))
+	taken := make(map[string]bool)
+	for i, t := range targets {
+		base := strings.TrimSuffix(OutputName(t), ".har")
+		name := base
+		// Case-insensitive file systems would still merge Example.com and example.com
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[strings.ToLower(name)] = true
+		names[i] = name + ".har"
+	}
+	return names
+}
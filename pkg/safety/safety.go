@@ -0,0 +1,313 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/m-1tZ/reqtrack/pkg/cookies"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Default deny-list of element texts (case-insensitive regexes)
+var DefaultDenyText = []string{
+	`\blog ?out\b`, `\bsign ?out\b`, `\blog ?off\b`, `\babmelden\b`,
+	`\bdelete\b`, `\bremove\b`, `\bdestroy\b`, `\bdeactivate\b`, `\bunsubscribe\b`,
+	`\bpay\b`, `\bpay now\b`, `\bpurchase\b`, `\bcheckout\b`, `\bbuy\b`, `\btransfer\b`,
+}
+
+// Default deny-list of CSS selectors
+var DefaultDenySelectors = []string{
+	`[href*="logout" i]`, `[href*="signout" i]`, `[href*="delete" i]`,
+	`[action*="logout" i]`, `[action*="delete" i]`,
+}
+
+// Default pattern for logout endpoints, requests to them are always blocked
+const DefaultLogoutPattern = `(?i)/(log-?out|sign-?out|log-?off|sign-?off|abmelden)\b`
+
+// Method policies for state-changing requests (POST/PUT/PATCH/DELETE)
+const (
+	MethodAllow  = "allow"  // send them
+	MethodRecord = "record" // send them and report them
+	MethodBlock  = "block"  // abort them, they are still kept as HAR entries
+)
+
+// Config shared by all targets
+type Config struct {
+	DenyText      []string
+	DenySelectors []string
+	LogoutPattern string
+	MethodPolicy  string
+}
+
+// Suppressed describes an action or request the guard did not let through
+type Suppressed struct {
+	Kind   string // element, function, form, request or logout
+	Target string
+	Reason string
+}
+
+// Guard enforces a Config on a single BrowserContext
+type Guard struct {
+	cfg    Config
+	logout *regexp.Regexp
+
+	mu         sync.Mutex
+	suppressed []Suppressed
+	recorded   []*structs.HAREntry
+	session    []pw.Cookie
+}
+
+// New validates cfg and returns a Guard for one target
+func New(cfg Config) (*Guard, error) {
+	for _, t := range cfg.DenyText {
+		if _, err := regexp.Compile("(?i)" + t); err != nil {
+			return nil, fmt.Errorf("invalid deny text %q: %w", t, err)
+		}
+	}
+	switch cfg.MethodPolicy {
+	case "":
+		cfg.MethodPolicy = MethodAllow
+	case MethodAllow, MethodRecord, MethodBlock:
+	default:
+		return nil, fmt.Errorf("unknown method policy %q", cfg.MethodPolicy)
+	}
+
+	g := &Guard{cfg: cfg}
+	if cfg.LogoutPattern != "" {
+		re, err := regexp.Compile(cfg.LogoutPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logout pattern: %w", err)
+		}
+		g.logout = re
+	}
+	return g, nil
+}
+
+// Install wires the guard into browserCtx: the deny-list is injected into every frame
+// and state-changing or logout requests are handled by a route.
+// Must be called before the first page is opened.
+func (g *Guard) Install(browserCtx pw.BrowserContext) error {
+	if g == nil {
+		return nil
+	}
+
+	// ---- Element deny-list (used by the trigger and form JS) ----
+	err := browserCtx.ExposeFunction("__reqtrackSuppressed", func(args ...interface{}) interface{} {
+		if len(args) < 3 {
+			return nil
+		}
+		g.suppress(fmt.Sprint(args[0]), fmt.Sprint(args[1]), fmt.Sprint(args[2]))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	denyJSON, err := json.Marshal(map[string][]string{
+		"text":      g.cfg.DenyText,
+		"selectors": g.cfg.DenySelectors,
+	})
+	if err != nil {
+		return err
+	}
+	if err := browserCtx.AddInitScript(pw.Script{Content: pw.String(strings.Replace(getDenyJS(), "__DENY_CONFIG__", string(denyJSON), 1))}); err != nil {
+		return err
+	}
+
+	// ---- Request policy ----
+	if g.logout == nil && g.cfg.MethodPolicy == MethodAllow {
+		return nil
+	}
+	return browserCtx.Route("**/*", func(route pw.Route) {
+		req := route.Request()
+
+		if g.logout != nil && g.logout.MatchString(req.URL()) {
+			g.suppress("logout", req.Method()+" "+req.URL(), "matches logout pattern")
+			g.record(req)
+			route.Abort("blockedbyclient")
+			return
+		}
+
+		if isStateChanging(req.Method()) {
+			switch g.cfg.MethodPolicy {
+			case MethodRecord:
+				g.suppress("request", req.Method()+" "+req.URL(), "state-changing method recorded")
+			case MethodBlock:
+				g.suppress("request", req.Method()+" "+req.URL(), "state-changing method blocked")
+				g.record(req)
+				route.Abort("blockedbyclient")
+				return
+			}
+		}
+		route.Fallback()
+	})
+}
+
+// CheckSession detects a logout by comparing the cookie jar with the first snapshot.
+// Cookies that vanished are put back, so the rest of the crawl stays authenticated.
+func (g *Guard) CheckSession(browserCtx pw.BrowserContext) {
+	if g == nil {
+		return
+	}
+	jar, err := browserCtx.Cookies()
+	if err != nil {
+		log.Printf("Safety: reading cookies failed: %v", err)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.session == nil {
+		g.session = jar
+		return
+	}
+
+	present := make(map[string]bool, len(jar))
+	for _, c := range jar {
+		present[c.Domain+c.Path+c.Name] = true
+	}
+	var missing []pw.OptionalCookie
+	for _, c := range g.session {
+		if !present[c.Domain+c.Path+c.Name] {
+			missing = append(missing, cookies.ToOptional(c))
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	log.Printf("Safety: logout detected, %d session cookies vanished, restoring them", len(missing))
+	g.suppressed = append(g.suppressed, Suppressed{Kind: "logout", Target: "session cookies", Reason: "session restored"})
+	if err := browserCtx.AddCookies(missing); err != nil {
+		log.Printf("Safety: restoring session failed: %v", err)
+	}
+}
+
+// ForgetSession drops the cookie snapshot, the next CheckSession takes a new one.
+// Used after a re-authentication replaced the session cookies.
+func (g *Guard) ForgetSession() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.session = nil
+	g.mu.Unlock()
+}
+
+// Report logs every suppressed action and returns the requests the guard kept out of the browser as HAR entries
+func (g *Guard) Report(targetURL string) []*structs.HAREntry {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.suppressed) > 0 {
+		log.Printf("Safety: %d actions suppressed on %s", len(g.suppressed), targetURL)
+		for _, s := range g.suppressed {
+			log.Printf("  [%s] %s (%s)", s.Kind, s.Target, s.Reason)
+		}
+	}
+	return g.recorded
+}
+
+func (g *Guard) suppress(kind, target, reason string) {
+	if len(target) > 120 {
+		target = target[:120] + "..."
+	}
+	g.mu.Lock()
+	g.suppressed = append(g.suppressed, Suppressed{Kind: kind, Target: target, Reason: reason})
+	g.mu.Unlock()
+}
+
+// record keeps a request that never reached the server as HAR entry
+func (g *Guard) record(req pw.Request) {
+	headers := req.Headers()
+	body, _ := req.PostData()
+	entry := helper.NewHAREntry(req.Method(), req.URL(), headers, body, headers["content-type"])
+	entry.Source = "blocked"
+
+	g.mu.Lock()
+	g.recorded = append(g.recorded, entry)
+	g.mu.Unlock()
+}
+
+func isStateChanging(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// getDenyJS defines window.__reqtrackIsDenied(el, kind) and window.__reqtrackIsDeniedName(name, kind).
+// __DENY_CONFIG__ is replaced with the JSON encoded deny-list.
+func getDenyJS() string {
+	return `(() => {
+		if (window.__reqtrackIsDenied) return;
+		const cfg = __DENY_CONFIG__;
+		const texts = (cfg.text || []).map(t => { try { return new RegExp(t, 'i'); } catch (e) { return null; } }).filter(Boolean);
+		const selectors = cfg.selectors || [];
+		const reported = new WeakSet();
+		const interactive = 'a,button,[role=button],[role=menuitem],[role=link],input[type=submit],input[type=button],input[type=image],summary,label';
+
+		const report = (kind, what, reason) => {
+			try { window.__reqtrackSuppressed(kind, what, reason); } catch (e) {}
+		};
+		const describe = (el) => {
+			const txt = (el.innerText || el.value || '').trim().replace(/\s+/g, ' ').slice(0, 60);
+			return el.tagName.toLowerCase() + (el.id ? '#' + el.id : '') + (txt ? ' "' + txt + '"' : '');
+		};
+		// Own text only for non-interactive elements, innerText of a container would match everything
+		const label = (el, own) => {
+			const parts = [el.getAttribute('aria-label'), el.getAttribute('title'), el.getAttribute('onclick'), el.value];
+			if (own) {
+				for (const n of el.childNodes) if (n.nodeType === 3) parts.push(n.textContent);
+			} else {
+				parts.push(el.innerText);
+			}
+			if (el.tagName === 'FORM') parts.push(el.getAttribute('action'));
+			return parts.filter(Boolean).join(' ');
+		};
+
+		const check = (el) => {
+			for (const sel of selectors) {
+				try { if (el.matches(sel) || (el.closest && el.closest(sel))) return 'selector ' + sel; } catch (e) {}
+			}
+			const target = (el.closest && el.closest(interactive)) || el;
+			const lbl = label(target, target === el && !el.matches(interactive));
+			for (const re of texts) {
+				if (re.test(lbl)) return 'text ' + re.source;
+			}
+			return '';
+		};
+
+		Object.defineProperty(window, '__reqtrackIsDenied', {
+			enumerable: false,
+			value: (el, kind) => {
+				if (!el || el.nodeType !== 1) return false;
+				const reason = check(el);
+				if (reason && !reported.has(el)) {
+					reported.add(el);
+					report(kind || 'element', describe(el), reason);
+				}
+				return !!reason;
+			},
+		});
+		Object.defineProperty(window, '__reqtrackIsDeniedName', {
+			enumerable: false,
+			value: (name, kind) => {
+				const re = texts.find(re => re.test(String(name).replace(/([a-z])([A-Z])/g, '$1 $2')));
+				if (re) report(kind || 'function', String(name), 'text ' + re.source);
+				return !!re;
+			},
+		});
+	})();`
+}
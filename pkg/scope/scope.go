@@ -0,0 +1,266 @@
+package scope
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Scope decides which URLs reqtrack navigates to, fetches and keeps in its output.
+// A nil *Scope treats every URL as in scope.
+type Scope struct {
+	IncludeHosts []string // host globs, e.g. *.example.com
+	ExcludeHosts []string
+	IncludePaths []*regexp.Regexp
+	ExcludePaths []*regexp.Regexp
+	Ports        []string // empty allows every port
+	Schemes      []string // empty allows every scheme
+}
+
+func New() *Scope {
+	return &Scope{}
+}
+
+// ForTarget returns a copy of s for targetURL.
+// Without include hosts only the host of targetURL is in scope.
+func (s *Scope) ForTarget(targetURL string) (*Scope, error) {
+	t := New()
+	if s != nil {
+		t.IncludeHosts = slices.Clone(s.IncludeHosts)
+		t.ExcludeHosts = slices.Clone(s.ExcludeHosts)
+		t.IncludePaths = slices.Clone(s.IncludePaths)
+		t.ExcludePaths = slices.Clone(s.ExcludePaths)
+		t.Ports = slices.Clone(s.Ports)
+		t.Schemes = slices.Clone(s.Schemes)
+	}
+	if len(t.IncludeHosts) == 0 {
+		u, err := url.Parse(targetURL)
+		if err != nil {
+			return nil, err
+		}
+		t.IncludeHosts = append(t.IncludeHosts, strings.ToLower(u.Hostname()))
+	}
+	return t, nil
+}
+
+// AddRule adds a single rule. kind is one of host, path, port or scheme,
+// include selects between the include and exclude list (ports and schemes are include only).
+func (s *Scope) AddRule(kind string, value string, include bool) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	switch kind {
+	case "host":
+		value = strings.ToLower(value)
+		// validate glob syntax early
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid host glob %q: %w", value, err)
+		}
+		if include {
+			s.IncludeHosts = append(s.IncludeHosts, value)
+		} else {
+			s.ExcludeHosts = append(s.ExcludeHosts, value)
+		}
+	case "path":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("invalid path regex %q: %w", value, err)
+		}
+		if include {
+			s.IncludePaths = append(s.IncludePaths, re)
+		} else {
+			s.ExcludePaths = append(s.ExcludePaths, re)
+		}
+	case "port":
+		if !include {
+			return fmt.Errorf("port rules can only be included")
+		}
+		s.Ports = append(s.Ports, value)
+	case "scheme":
+		if !include {
+			return fmt.Errorf("scheme rules can only be included")
+		}
+		s.Schemes = append(s.Schemes, strings.ToLower(value))
+	default:
+		return fmt.Errorf("unknown scope rule %q", kind)
+	}
+	return nil
+}
+
+// AddRules adds a comma separated list of rules of the same kind
+func (s *Scope) AddRules(kind string, list string, include bool) error {
+	for _, v := range strings.Split(list, ",") {
+		if err := s.AddRule(kind, v, include); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile reads rules from a file, one per line:
+//
+//	include host *.example.com
+//	exclude path ^/logout
+//	port 443,8443
+//	scheme https
+//
+// Empty lines and lines starting with # are ignored.
+func (s *Scope) LoadFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		var err error
+		switch {
+		case (fields[0] == "include" || fields[0] == "exclude") && len(fields) == 3:
+			err = s.AddRules(fields[1], fields[2], fields[0] == "include")
+		case (fields[0] == "port" || fields[0] == "scheme") && len(fields) == 2:
+			err = s.AddRules(fields[0], fields[1], true)
+		default:
+			err = fmt.Errorf("malformed rule")
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filePath, lineNo, err)
+		}
+	}
+	return sc.Err()
+}
+
+// InScope reports whether rawURL passes the scheme, port, host and path rules.
+// Without include hosts or include paths every host or path is accepted, excludes always win.
+func (s *Scope) InScope(rawURL string) bool {
+	if s == nil {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if len(s.Schemes) > 0 && !slices.Contains(s.Schemes, scheme) {
+		return false
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+	if len(s.Ports) > 0 && !slices.Contains(s.Ports, port) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(s.IncludeHosts) > 0 && !matchHost(s.IncludeHosts, host) {
+		return false
+	}
+	if matchHost(s.ExcludeHosts, host) {
+		return false
+	}
+
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if len(s.IncludePaths) > 0 && !matchPath(s.IncludePaths, p) {
+		return false
+	}
+	if matchPath(s.ExcludePaths, p) {
+		return false
+	}
+
+	return true
+}
+
+// FilterEntries drops all HAR entries whose request URL is out of scope
+func (s *Scope) FilterEntries(entries []*structs.HAREntry) []*structs.HAREntry {
+	if s == nil {
+		return entries
+	}
+	var kept []*structs.HAREntry
+	dropped := 0
+	for _, e := range entries {
+		if s.InScope(e.Request.URL) {
+			kept = append(kept, e)
+		} else {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.Printf("Scope: dropped %d out-of-scope entries", dropped)
+	}
+	return kept
+}
+
+// Enforce aborts main-frame navigations to out-of-scope URLs in every page of browserCtx.
+// Sub-resources and third-party frames are left alone so the application keeps working,
+// they are filtered from the output instead.
+func (s *Scope) Enforce(browserCtx pw.BrowserContext) error {
+	if s == nil {
+		return nil
+	}
+	return browserCtx.Route("**/*", func(route pw.Route) {
+		req := route.Request()
+		if isMainFrameNavigation(req) && !s.InScope(req.URL()) {
+			log.Printf("Scope: blocked navigation to %s", req.URL())
+			route.Abort("blockedbyclient")
+			return
+		}
+		route.Fallback()
+	})
+}
+
+// isMainFrameNavigation reports whether req navigates a page, iframe navigations are not
+func isMainFrameNavigation(req pw.Request) bool {
+	if !req.IsNavigationRequest() {
+		return false
+	}
+	frame := req.Frame()
+	return frame != nil && frame.ParentFrame() == nil
+}
+
+func matchHost(globs []string, host string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(res []*regexp.Regexp, p string) bool {
+	for _, re := range res {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
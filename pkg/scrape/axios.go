@@ -0,0 +1,289 @@
+package scrape
+
+import (
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// axiosInstance is an axios.create instance (or axios itself) with the defaults its calls inherit
+type axiosInstance struct {
+	name    string
+	lib     string // axios or redaxios, which share the API
+	baseURL string
+	headers []structs.HARNameValue
+}
+
+// setHeaders adds or replaces headers case-insensitively
+func (a *axiosInstance) setHeaders(hdrs []structs.HARNameValue) {
+	a.headers = setHeaders(a.headers, hdrs)
+}
+
+// Request methods of axios and its instances
+var axiosMethods = map[string]bool{
+	"request": true, "get": true, "delete": true, "head": true, "options": true,
+	"post": true, "put": true, "patch": true, "postForm": true, "putForm": true, "patchForm": true,
+}
+
+// memberPath flattens a.b["c"].d into [a b c d], nil for computed or call parts
+func (r *resolver) memberPath(node *sitter.Node) []string {
+	if node == nil {
+		return nil
+	}
+	switch node.Type() {
+	case "identifier", "this", "property_identifier":
+		return []string{node.Content(r.src)}
+	case "member_expression":
+		obj := r.memberPath(node.ChildByFieldName("object"))
+		prop := node.ChildByFieldName("property")
+		if obj == nil || prop == nil {
+			return nil
+		}
+		return append(obj, prop.Content(r.src))
+	case "subscript_expression":
+		obj := r.memberPath(node.ChildByFieldName("object"))
+		idx := node.ChildByFieldName("index")
+		if obj == nil || idx == nil || idx.Type() != "string" {
+			return nil
+		}
+		return append(obj, r.extractString(idx))
+	}
+	return nil
+}
+
+// literalHeaders returns the string pairs of a headers object
+func (r *resolver) literalHeaders(objNode *sitter.Node) []structs.HARNameValue {
+	out := []structs.HARNameValue{}
+	objNode = r.valueOf(objNode, 0)
+	if objNode == nil || objNode.Type() != "object" {
+		return out
+	}
+	for i := 0; i < int(objNode.NamedChildCount()); i++ {
+		child := objNode.NamedChild(i)
+		if child.Type() != "pair" {
+			continue
+		}
+		// Values built at runtime are left to the header synthesis
+		value, ok := r.constString(child.ChildByFieldName("value"), 0)
+		if !ok {
+			continue
+		}
+		out = append(out, structs.HARNameValue{Name: strings.Trim(child.ChildByFieldName("key").Content(r.src), `"'`), Value: value})
+	}
+	return out
+}
+
+// Instance config: baseURL and headers, including headers.common
+func (w *walker) readInstanceConfig(inst *axiosInstance, cfg *sitter.Node) {
+	cfg = w.valueOf(cfg, 0)
+	if cfg == nil || cfg.Type() != "object" {
+		return
+	}
+	if b := w.extractString(w.objectPropertyNode(cfg, "baseURL")); b != "" {
+		inst.baseURL = b
+	}
+	hdrs := w.objectPropertyNode(cfg, "headers")
+	inst.setHeaders(w.literalHeaders(hdrs))
+	inst.setHeaders(w.literalHeaders(w.objectPropertyNode(hdrs, "common")))
+}
+
+// Assignments to <instance>.defaults.baseURL / .defaults.headers[.common].<name> or, inside a
+// request interceptor, to <config>.baseURL / <config>.headers.<name>
+func (w *walker) applyAssignment(inst *axiosInstance, path []string, value *sitter.Node) {
+	v := w.extractString(value)
+	if v == "" || strings.Contains(v, "${") {
+		return
+	}
+	switch {
+	case len(path) == 1 && path[0] == "baseURL":
+		inst.baseURL = v
+	case len(path) == 2 && path[0] == "headers":
+		inst.setHeaders([]structs.HARNameValue{{Name: path[1], Value: v}})
+	case len(path) == 3 && path[0] == "headers" && path[1] == "common":
+		inst.setHeaders([]structs.HARNameValue{{Name: path[2], Value: v}})
+	}
+}
+
+// Request interceptor api.interceptors.request.use(config => { config.headers.X = "..."; return config })
+func (w *walker) readInterceptor(inst *axiosInstance, fn *sitter.Node) {
+	if fn == nil || (fn.Type() != "arrow_function" && fn.Type() != "function" && fn.Type() != "function_expression") {
+		return
+	}
+	param := ""
+	if p := fn.ChildByFieldName("parameter"); p != nil {
+		param = p.Content(w.src)
+	} else if ps := fn.ChildByFieldName("parameters"); ps != nil && ps.NamedChildCount() > 0 {
+		param = ps.NamedChild(0).Content(w.src)
+	}
+	if param == "" {
+		return
+	}
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		if n.Type() == "assignment_expression" {
+			path := w.memberPath(n.ChildByFieldName("left"))
+			if len(path) > 1 && path[0] == param {
+				w.applyAssignment(inst, path[1:], n.ChildByFieldName("right"))
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			visit(n.NamedChild(i))
+		}
+	}
+	visit(fn)
+}
+
+// isAxiosCreate returns the library and config argument of axios.create(config), lib is empty for other nodes
+func (w *walker) isAxiosCreate(n *sitter.Node) (string, *sitter.Node) {
+	if n == nil || n.Type() != "call_expression" {
+		return "", nil
+	}
+	path := w.memberPath(n.ChildByFieldName("function"))
+	if len(path) != 2 || path[1] != "create" {
+		return "", nil
+	}
+	parent, ok := w.axiosInstances[path[0]]
+	if !ok {
+		return "", nil
+	}
+	if args := n.ChildByFieldName("arguments"); args != nil && args.NamedChildCount() > 0 {
+		return parent.lib, args.NamedChild(0)
+	}
+	return parent.lib, nil
+}
+
+// collectAxios records axios.create instances, their defaults and request interceptors before the walk,
+// bundles often define an instance after the code that uses it
+func (w *walker) collectAxios(n *sitter.Node) {
+	switch n.Type() {
+	case "variable_declarator":
+		if lib, cfg := w.isAxiosCreate(n.ChildByFieldName("value")); lib != "" {
+			if name := n.ChildByFieldName("name"); name != nil && name.Type() == "identifier" {
+				inst := &axiosInstance{name: name.Content(w.src), lib: lib}
+				w.readInstanceConfig(inst, cfg)
+				w.axiosInstances[inst.name] = inst
+			}
+		}
+	case "assignment_expression":
+		left := w.memberPath(n.ChildByFieldName("left"))
+		if lib, cfg := w.isAxiosCreate(n.ChildByFieldName("right")); lib != "" && left != nil {
+			inst := &axiosInstance{name: strings.Join(left, "."), lib: lib}
+			w.readInstanceConfig(inst, cfg)
+			w.axiosInstances[inst.name] = inst
+			break
+		}
+		for i, seg := range left {
+			if seg != "defaults" {
+				continue
+			}
+			if inst, ok := w.axiosInstances[strings.Join(left[:i], ".")]; ok {
+				w.applyAssignment(inst, left[i+1:], n.ChildByFieldName("right"))
+			}
+			break
+		}
+	case "call_expression":
+		path := w.memberPath(n.ChildByFieldName("function"))
+		if l := len(path); l >= 4 && strings.Join(path[l-3:], ".") == "interceptors.request.use" {
+			if inst, ok := w.axiosInstances[strings.Join(path[:l-3], ".")]; ok {
+				if args := n.ChildByFieldName("arguments"); args != nil && args.NamedChildCount() > 0 {
+					w.readInterceptor(inst, args.NamedChild(0))
+				}
+			}
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		w.collectAxios(n.NamedChild(i))
+	}
+}
+
+// axiosBody returns the request body of a data argument and the Content-Type axios sends it with
+func (w *walker) axiosBody(node *sitter.Node) (string, string) {
+	if node == nil {
+		return "", ""
+	}
+	switch node.Type() {
+	case "string", "template_string":
+		return w.extractString(node), "application/x-www-form-urlencoded"
+	case "object", "array":
+		return node.Content(w.src), "application/json"
+	case "new_expression":
+		ctor := node.ChildByFieldName("constructor")
+		if ctor != nil && ctor.Content(w.src) == "URLSearchParams" {
+			return node.Content(w.src), "application/x-www-form-urlencoded"
+		}
+		if ctor != nil && ctor.Content(w.src) == "FormData" {
+			return node.Content(w.src), "multipart/form-data"
+		}
+		return node.Content(w.src), ""
+	case "call_expression":
+		return node.Content(w.src), ""
+	}
+	return "", ""
+}
+
+// decodeAxios returns method, URL, body, Content-Type and headers of a call through inst.
+// method is the called method (get, post, ...) or "axios" for the callable form.
+func (w *walker) decodeAxios(method string, args []*sitter.Node, inst *axiosInstance) (string, string, string, string, []structs.HARNameValue) {
+	var url, body, ctype string
+	var config *sitter.Node
+
+	switch method {
+	case "get", "delete", "head", "options":
+		url, config = w.extractString(arg(args, 0)), arg(args, 1)
+	case "post", "put", "patch", "postForm", "putForm", "patchForm":
+		url, config = w.extractString(arg(args, 0)), arg(args, 2)
+		body, ctype = w.axiosBody(arg(args, 1))
+		if strings.HasSuffix(method, "Form") {
+			method = strings.TrimSuffix(method, "Form")
+			ctype = "multipart/form-data"
+		}
+	default:
+		// axios(config), axios(url, config), api.request(config)
+		method = ""
+		if a := arg(args, 0); a != nil && a.Type() == "object" {
+			config = a
+		} else {
+			url, config = w.extractString(a), arg(args, 1)
+		}
+	}
+	if config != nil && config.Type() != "object" {
+		config = nil
+	}
+
+	baseURL := inst.baseURL
+	if config != nil {
+		if url == "" {
+			url = w.extractString(w.objectPropertyNode(config, "url"))
+		}
+		if method == "" {
+			method = w.extractString(w.objectPropertyNode(config, "method"))
+		}
+		if body == "" {
+			body, ctype = w.axiosBody(w.objectPropertyNode(config, "data"))
+		}
+		if b := w.extractString(w.objectPropertyNode(config, "baseURL")); b != "" {
+			baseURL = b
+		}
+	}
+
+	// Instance defaults first, headers of the call override them
+	callHeaders := w.literalHeaders(w.objectPropertyNode(config, "headers"))
+	hdrs := []structs.HARNameValue{}
+	for _, h := range inst.headers {
+		if !helper.HasHeader(callHeaders, h.Name) {
+			hdrs = append(hdrs, h)
+		}
+	}
+	hdrs = append(hdrs, callHeaders...)
+	for _, h := range hdrs {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			ctype = h.Value
+		}
+	}
+
+	// params are serialized into the query string
+	url = appendQuery(joinBaseURL(baseURL, url), w.jqData(w.objectPropertyNode(config, "params")))
+	return strings.ToUpper(method), url, body, ctype, hdrs
+}
@@ -0,0 +1,694 @@
+package scrape
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// --- HTTP client libraries: Angular HttpClient, ky, got, ofetch, superagent, wretch, GraphQL ---
+
+// A decoded library call, prim names the client in _client
+type clientCall struct {
+	prim, method, url, body, ctype string
+	headers                        []structs.HARNameValue
+}
+
+// A method call of a builder chain, e.g. .set("X-A", "b") of superagent
+type chainLink struct {
+	name string
+	args []*sitter.Node
+}
+
+var (
+	// Names the libraries are imported under without an import, extended by imports and requires before the walk
+	defaultClientAliases = map[string]string{
+		"ky": "ky", "got": "got", "ofetch": "ofetch", "$fetch": "ofetch", "superagent": "superagent", "wretch": "wretch",
+	}
+	clientModules = map[string]string{
+		"axios": "axios", "redaxios": "redaxios", "ky": "ky", "ky-universal": "ky", "got": "got",
+		"ofetch": "ofetch", "ohmyfetch": "ofetch", "superagent": "superagent", "wretch": "wretch",
+	}
+
+	// Method shortcuts of ky and got: ky.post(url, options)
+	optionMethods = map[string]bool{"get": true, "post": true, "put": true, "patch": true, "delete": true, "head": true}
+
+	angularMethods = map[string]bool{
+		"get": true, "post": true, "put": true, "patch": true, "delete": true, "head": true, "options": true, "jsonp": true, "request": true,
+	}
+
+	// superagent's shorthands for .type() and .accept()
+	superagentTypes = map[string]string{
+		"json": "application/json", "form": "application/x-www-form-urlencoded", "urlencoded": "application/x-www-form-urlencoded",
+		"html": "text/html", "xml": "application/xml", "text": "text/plain",
+	}
+	superagentMethods = map[string]string{
+		"get": "GET", "post": "POST", "put": "PUT", "patch": "PATCH", "del": "DELETE", "delete": "DELETE", "head": "HEAD", "options": "OPTIONS",
+	}
+
+	// ${...} of a template literal
+	templateSubstitution = regexp.MustCompile(`\$\{[^}]*\}`)
+	// First definition of a GraphQL document: its keyword (or "{" for the query shorthand) and name
+	graphQLOperation = regexp.MustCompile(`^(?:\s|#[^\n]*)*(query|mutation|subscription|fragment|\{)\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+)
+
+func (r *resolver) namedArgs(call *sitter.Node) []*sitter.Node {
+	var out []*sitter.Node
+	if argsNode := call.ChildByFieldName("arguments"); argsNode != nil && argsNode.Type() == "arguments" {
+		for i := 0; i < int(argsNode.NamedChildCount()); i++ {
+			out = append(out, r.valueOf(argsNode.NamedChild(i), 0))
+		}
+	}
+	return out
+}
+
+func arg(args []*sitter.Node, i int) *sitter.Node {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// callbackLast reports whether the last argument is a function, as in Node's http.get(url, res => ...)
+func callbackLast(args []*sitter.Node) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[len(args)-1].Type() {
+	case "function", "function_expression", "arrow_function":
+		return true
+	}
+	return false
+}
+
+// literal returns the value of a string argument, "" when it can't be resolved
+func (r *resolver) literal(node *sitter.Node) string {
+	if node == nil || node.Type() == "object" {
+		return ""
+	}
+	return r.extractString(node)
+}
+
+// climbChain returns the method calls chained onto call, e.g. .send(x).set(a, b) of superagent.post(url)
+func (r *resolver) climbChain(call *sitter.Node) []chainLink {
+	var links []chainLink
+	for {
+		member := call.Parent()
+		if member == nil || member.Type() != "member_expression" {
+			return links
+		}
+		obj, prop := member.ChildByFieldName("object"), member.ChildByFieldName("property")
+		if obj == nil || prop == nil || obj.StartByte() != call.StartByte() || obj.EndByte() != call.EndByte() {
+			return links
+		}
+		next := member.Parent()
+		if next == nil || next.Type() != "call_expression" {
+			return links
+		}
+		links = append(links, chainLink{name: prop.Content(r.src), args: r.namedArgs(next)})
+		call = next
+	}
+}
+
+// rootCall descends a chain like wretch(url).headers(h).auth(a) to its first call
+func rootCall(n *sitter.Node) *sitter.Node {
+	for n != nil && n.Type() == "call_expression" {
+		fn := n.ChildByFieldName("function")
+		if fn == nil || fn.Type() != "member_expression" {
+			return n
+		}
+		obj := fn.ChildByFieldName("object")
+		if obj == nil || obj.Type() != "call_expression" {
+			return n
+		}
+		n = obj
+	}
+	return n
+}
+
+// constructorName returns the last segment of the constructor of new X(...) or new ns.X(...)
+func (r *resolver) constructorName(node *sitter.Node) string {
+	if node == nil || node.Type() != "new_expression" {
+		return ""
+	}
+	ctor := node.ChildByFieldName("constructor")
+	if ctor == nil {
+		return ""
+	}
+	name := ctor.Content(r.src)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// firstArg returns the first argument of a new_expression or call_expression
+func (r *resolver) firstArg(node *sitter.Node) *sitter.Node {
+	if argsNode := node.ChildByFieldName("arguments"); argsNode != nil && argsNode.NamedChildCount() > 0 {
+		return r.valueOf(argsNode.NamedChild(0), 0)
+	}
+	return nil
+}
+
+// headersOf reads a headers object, new Headers({...}) or Angular's new HttpHeaders({...})
+func (r *resolver) headersOf(node *sitter.Node) []structs.HARNameValue {
+	switch r.constructorName(node) {
+	case "Headers", "HttpHeaders":
+		return r.literalHeaders(r.firstArg(node))
+	}
+	return r.literalHeaders(node)
+}
+
+// paramsOf serializes query parameters: objects, strings, new URLSearchParams(...) and
+// Angular's new HttpParams({fromObject: {...}}) / new HttpParams({fromString: "..."})
+func (r *resolver) paramsOf(node *sitter.Node) string {
+	switch r.constructorName(node) {
+	case "URLSearchParams":
+		return r.jqData(r.firstArg(node))
+	case "HttpParams":
+		init := r.firstArg(node)
+		if s := r.literal(r.objectPropertyNode(init, "fromString")); s != "" {
+			return s
+		}
+		return r.jqData(r.objectPropertyNode(init, "fromObject"))
+	}
+	return r.jqData(node)
+}
+
+// clientBody returns a body argument and the Content-Type fetch based clients send it with:
+// objects are serialized to JSON, strings are sent as text
+func (r *resolver) clientBody(node *sitter.Node) (string, string) {
+	if node == nil {
+		return "", ""
+	}
+	switch node.Type() {
+	case "string", "template_string":
+		return r.extractString(node), "text/plain;charset=UTF-8"
+	case "object", "array":
+		return node.Content(r.src), "application/json"
+	case "new_expression":
+		switch r.constructorName(node) {
+		case "FormData":
+			return node.Content(r.src), "multipart/form-data"
+		case "URLSearchParams", "HttpParams":
+			return r.paramsOf(node), "application/x-www-form-urlencoded;charset=UTF-8"
+		}
+		return node.Content(r.src), ""
+	case "call_expression":
+		if fn := node.ChildByFieldName("function"); fn != nil && fn.Content(r.src) == "JSON.stringify" {
+			return node.Content(r.src), "application/json"
+		}
+		return node.Content(r.src), ""
+	}
+	return "", ""
+}
+
+// finish takes the Content-Type from explicit headers and defaults the method
+func finish(c *clientCall) *clientCall {
+	for _, h := range c.headers {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			c.ctype = h.Value
+		}
+	}
+	c.method = strings.ToUpper(c.method)
+	if c.method == "" {
+		c.method = "GET"
+	}
+	if c.headers == nil {
+		c.headers = []structs.HARNameValue{}
+	}
+	return c
+}
+
+// decodeOptionsCall decodes ky(url, options), got(url, options) and ofetch(url, options)
+func (w *walker) decodeOptionsCall(lib, method string, args []*sitter.Node) *clientCall {
+	c := &clientCall{prim: lib, method: method}
+	if method != "" {
+		c.prim = lib + "." + method
+	}
+	opts := arg(args, 1)
+	if a := arg(args, 0); a != nil && a.Type() == "object" {
+		// got(options)
+		opts = a
+		c.url = w.literal(w.objectPropertyNode(opts, "url"))
+	} else {
+		c.url = w.literal(a)
+	}
+	if opts == nil || opts.Type() != "object" {
+		return finish(c)
+	}
+
+	if c.method == "" {
+		c.method = w.literal(w.objectPropertyNode(opts, "method"))
+	}
+	c.headers = w.headersOf(w.objectPropertyNode(opts, "headers"))
+	if j := w.objectPropertyNode(opts, "json"); j != nil {
+		c.body, c.ctype = j.Content(w.src), "application/json"
+	} else if f := w.objectPropertyNode(opts, "form"); f != nil {
+		c.body, c.ctype = w.jqData(f), "application/x-www-form-urlencoded"
+	} else {
+		c.body, c.ctype = w.clientBody(w.objectPropertyNode(opts, "body"))
+	}
+
+	prefix, query := "prefixUrl", "searchParams"
+	if lib == "ofetch" {
+		prefix, query = "baseURL", "query"
+		if w.objectPropertyNode(opts, query) == nil {
+			query = "params"
+		}
+	}
+	c.url = appendQuery(joinBaseURL(w.literal(w.objectPropertyNode(opts, prefix)), c.url), w.paramsOf(w.objectPropertyNode(opts, query)))
+	return finish(c)
+}
+
+// Angular HttpClient, injected as this.http, this.httpClient or this._http. Only trusted once the
+// script shows Angular, a receiver named http is just as likely Node's http module.
+func (w *walker) angularClient(name string) bool {
+	name = strings.ToLower(strings.TrimPrefix(name, "_"))
+	return w.angularSeen && (name == "http" || name == "httpclient")
+}
+
+// angularEvidence matches an @angular/common/http import, an injected HttpClient
+// (inject(HttpClient), ɵɵinject(HttpClient), ctorParameters {type: HttpClient}) and Ivy definitions
+func (w *walker) angularEvidence(n *sitter.Node) bool {
+	switch n.Type() {
+	case "import_statement":
+		return w.literal(n.ChildByFieldName("source")) == "@angular/common/http"
+	case "call_expression":
+		fn := n.ChildByFieldName("function")
+		if fn == nil {
+			return false
+		}
+		name := fn.Content(w.src)
+		if fn.Type() == "member_expression" {
+			name = fn.ChildByFieldName("property").Content(w.src)
+		}
+		switch name {
+		case "inject", "ɵɵinject", "ɵɵdirectiveInject":
+			args := n.ChildByFieldName("arguments")
+			return args != nil && args.NamedChildCount() > 0 && strings.HasSuffix(args.NamedChild(0).Content(w.src), "HttpClient")
+		}
+	case "pair":
+		key, value := n.ChildByFieldName("key"), n.ChildByFieldName("value")
+		return key != nil && value != nil && key.Content(w.src) == "type" && value.Content(w.src) == "HttpClient"
+	case "property_identifier":
+		switch n.Content(w.src) {
+		case "ɵfac", "ɵprov", "ɵcmp":
+			return true
+		}
+	}
+	return false
+}
+
+// decodeAngular decodes get(url, options), post(url, body, options) and request(method, url, options)
+func (w *walker) decodeAngular(method string, args []*sitter.Node) *clientCall {
+	c := &clientCall{prim: "HttpClient." + method, method: method}
+	var opts *sitter.Node
+	switch method {
+	case "post", "put", "patch":
+		c.url, opts = w.literal(arg(args, 0)), arg(args, 2)
+		c.body, c.ctype = w.clientBody(arg(args, 1))
+	case "request":
+		// The request(new HttpRequest(...)) overload is not resolved
+		c.method, c.url, opts = w.literal(arg(args, 0)), w.literal(arg(args, 1)), arg(args, 2)
+	case "jsonp":
+		c.method, c.url = "GET", w.literal(arg(args, 0))
+		if cb := w.literal(arg(args, 1)); cb != "" {
+			c.url = appendQuery(c.url, neturl.QueryEscape(cb)+"=JSONP_CALLBACK")
+		}
+	default:
+		c.url, opts = w.literal(arg(args, 0)), arg(args, 1)
+	}
+	if opts == nil || opts.Type() != "object" {
+		return finish(c)
+	}
+	if c.body == "" {
+		c.body, c.ctype = w.clientBody(w.objectPropertyNode(opts, "body"))
+	}
+	c.headers = w.headersOf(w.objectPropertyNode(opts, "headers"))
+	c.url = appendQuery(c.url, w.paramsOf(w.objectPropertyNode(opts, "params")))
+	return finish(c)
+}
+
+func mimeType(t string) string {
+	if m, ok := superagentTypes[t]; ok {
+		return m
+	}
+	return t
+}
+
+// decodeSuperagent follows superagent.post(url).set(...).type(...).query(...).send(data)
+func (w *walker) decodeSuperagent(c *clientCall, data *sitter.Node, links []chainLink) *clientCall {
+	for _, l := range links {
+		switch l.name {
+		case "send":
+			data = arg(l.args, 0)
+		case "set":
+			if h := arg(l.args, 0); h != nil && h.Type() == "object" {
+				c.headers = setHeaders(c.headers, w.literalHeaders(h))
+			} else if name, value := w.constant(h), w.constant(arg(l.args, 1)); name != "" && value != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: name, Value: value}})
+			}
+		case "type":
+			if t := w.literal(arg(l.args, 0)); t != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Content-Type", Value: mimeType(t)}})
+			}
+		case "accept":
+			if t := w.literal(arg(l.args, 0)); t != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Accept", Value: mimeType(t)}})
+			}
+		case "query":
+			c.url = appendQuery(c.url, w.jqData(arg(l.args, 0)))
+		case "auth":
+			user, pass := w.constant(arg(l.args, 0)), w.constant(arg(l.args, 1))
+			if user != "" && pass != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Authorization", Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))}})
+			}
+		}
+	}
+	finish(c)
+	if data == nil {
+		return c
+	}
+	// GET and HEAD send the data as query string, objects are JSON unless the type says form
+	if c.method == "GET" || c.method == "HEAD" {
+		c.url = appendQuery(c.url, w.jqData(data))
+		return c
+	}
+	switch {
+	case strings.Contains(c.ctype, "urlencoded"):
+		c.body = w.jqData(data)
+	case data.Type() == "object" || data.Type() == "array":
+		c.body, c.ctype = data.Content(w.src), "application/json"
+	default:
+		c.body = w.literal(data)
+		if c.body != "" && c.ctype == "" {
+			c.ctype = "application/x-www-form-urlencoded"
+		}
+	}
+	return c
+}
+
+// applyWretch applies wretch(url, options).url(...).headers(...).auth(...).json(body).post() to c.
+// done is true once a request method is reached, the rest of the chain reads the response.
+func (w *walker) applyWretch(c *clientCall, links []chainLink) (done bool) {
+	for _, l := range links {
+		a0, a1 := arg(l.args, 0), arg(l.args, 1)
+		switch l.name {
+		case "url":
+			if a1 != nil && a1.Type() == "true" {
+				c.url = w.literal(a0)
+			} else {
+				c.url += w.literal(a0)
+			}
+		case "headers":
+			c.headers = setHeaders(c.headers, w.headersOf(a0))
+		case "auth":
+			if v := w.constant(a0); v != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Authorization", Value: v}})
+			}
+		case "content":
+			if v := w.constant(a0); v != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Content-Type", Value: v}})
+			}
+		case "accept":
+			if v := w.constant(a0); v != "" {
+				c.headers = setHeaders(c.headers, []structs.HARNameValue{{Name: "Accept", Value: v}})
+			}
+		case "options":
+			if m := w.literal(w.objectPropertyNode(a0, "method")); m != "" {
+				c.method = m
+			}
+			c.headers = setHeaders(c.headers, w.headersOf(w.objectPropertyNode(a0, "headers")))
+		case "query":
+			c.url = appendQuery(c.url, w.paramsOf(a0))
+		case "json":
+			if a0 != nil {
+				c.body, c.ctype = a0.Content(w.src), "application/json"
+			}
+		case "body":
+			c.body, c.ctype = w.clientBody(a0)
+		case "formData":
+			if a0 != nil {
+				c.body, c.ctype = a0.Content(w.src), "multipart/form-data"
+			}
+		case "formUrl":
+			c.body, c.ctype = w.paramsOf(a0), "application/x-www-form-urlencoded"
+		case "get", "delete", "head", "opts":
+			// get(url) appends url to the base
+			c.method = l.name
+			if l.name == "opts" {
+				c.method = "OPTIONS"
+			}
+			c.url += w.literal(a0)
+			return true
+		case "post", "put", "patch":
+			// post(body, url), object bodies are sent as JSON
+			c.method = l.name
+			if a0 != nil {
+				c.body, c.ctype = w.clientBody(a0)
+			}
+			c.url += w.literal(a1)
+			return true
+		case "fetch":
+			c.method = w.literal(a0)
+			c.url += w.literal(a1)
+			c.body, c.ctype = w.clientBody(arg(l.args, 2))
+			return true
+		}
+	}
+	return false
+}
+
+// wretchRoot starts the state of wretch(url, options)
+func (w *walker) wretchRoot(args []*sitter.Node) *clientCall {
+	c := &clientCall{prim: "wretch", url: w.literal(arg(args, 0))}
+	if opts := arg(args, 1); opts != nil {
+		c.method = w.literal(w.objectPropertyNode(opts, "method"))
+		c.headers = w.headersOf(w.objectPropertyNode(opts, "headers"))
+	}
+	return c
+}
+
+// wretchBase copies the state of a wretch base, so requests don't change it
+func (w *walker) wretchBase(name string) *clientCall {
+	b, ok := w.wretchBases[name]
+	if !ok {
+		return nil
+	}
+	c := *b
+	c.headers = append([]structs.HARNameValue{}, b.headers...)
+	return &c
+}
+
+// decodeGraphQL turns a gql`...` document into the POST the client sends for it
+func (w *walker) decodeGraphQL(tmpl *sitter.Node) *clientCall {
+	// Interpolated fragments are left out, their definitions are separate documents
+	doc := strings.TrimSpace(templateSubstitution.ReplaceAllString(strings.Trim(tmpl.Content(w.src), "`"), ""))
+	m := graphQLOperation.FindStringSubmatch(doc)
+	// Fragments are only sent as part of an operation, subscriptions go over a WebSocket
+	if m == nil || m[1] == "fragment" || m[1] == "subscription" {
+		return nil
+	}
+	payload := map[string]interface{}{"query": doc, "variables": map[string]interface{}{}}
+	if m[1] != "{" && m[2] != "" {
+		payload["operationName"] = m[2]
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return finish(&clientCall{
+		prim:    "graphql",
+		method:  "POST",
+		url:     w.gqlEndpoint,
+		body:    string(body),
+		ctype:   "application/json",
+		headers: append([]structs.HARNameValue{}, w.gqlHeaders...),
+	})
+}
+
+// collectClients records import aliases, wretch bases and the GraphQL endpoint before the walk
+func (w *walker) collectClients(n *sitter.Node) {
+	if !w.angularSeen && w.angularEvidence(n) {
+		w.angularSeen = true
+	}
+	switch n.Type() {
+	case "import_statement":
+		lib := clientModules[w.literal(n.ChildByFieldName("source"))]
+		if lib == "" {
+			break
+		}
+		var visit func(c *sitter.Node)
+		visit = func(c *sitter.Node) {
+			switch c.Type() {
+			case "import_clause", "namespace_import":
+				// import ky from "ky", import * as request from "superagent"
+				for i := 0; i < int(c.NamedChildCount()); i++ {
+					if id := c.NamedChild(i); id.Type() == "identifier" {
+						if lib == "axios" || lib == "redaxios" {
+							w.axiosInstances[id.Content(w.src)] = &axiosInstance{name: id.Content(w.src), lib: lib}
+						} else {
+							w.clientAliases[id.Content(w.src)] = lib
+						}
+					}
+				}
+			case "import_specifier":
+				// import { ofetch as f, $fetch } from "ofetch"
+				name, alias := c.ChildByFieldName("name"), c.ChildByFieldName("alias")
+				if name != nil && w.clientAliases[name.Content(w.src)] == lib && alias != nil {
+					w.clientAliases[alias.Content(w.src)] = lib
+				}
+			}
+			for i := 0; i < int(c.NamedChildCount()); i++ {
+				visit(c.NamedChild(i))
+			}
+		}
+		visit(n)
+	case "variable_declarator":
+		name, value := n.ChildByFieldName("name"), n.ChildByFieldName("value")
+		if name == nil || name.Type() != "identifier" || value == nil || value.Type() != "call_expression" {
+			break
+		}
+		// const request = require("superagent")
+		if fn := value.ChildByFieldName("function"); fn != nil && fn.Content(w.src) == "require" {
+			if lib := clientModules[w.literal(w.firstArg(value))]; lib == "axios" || lib == "redaxios" {
+				w.axiosInstances[name.Content(w.src)] = &axiosInstance{name: name.Content(w.src), lib: lib}
+			} else if lib != "" {
+				w.clientAliases[name.Content(w.src)] = lib
+			}
+			break
+		}
+		// const api = wretch(base).headers(...), without a request method
+		root := rootCall(value)
+		fn := root.ChildByFieldName("function")
+		var c *clientCall
+		var links []chainLink
+		switch {
+		case fn == nil:
+		case w.clientAliases[fn.Content(w.src)] == "wretch":
+			c, links = w.wretchRoot(w.namedArgs(root)), w.climbChain(root)
+		case fn.Type() == "member_expression":
+			// const v2 = api.url("/v2") extends the base api
+			if c = w.wretchBase(fn.ChildByFieldName("object").Content(w.src)); c != nil {
+				links = append([]chainLink{{name: fn.ChildByFieldName("property").Content(w.src), args: w.namedArgs(root)}}, w.climbChain(root)...)
+			}
+		}
+		if c != nil && !w.applyWretch(c, links) {
+			c.prim = "wretch:" + name.Content(w.src)
+			w.wretchBases[name.Content(w.src)] = c
+		}
+	case "new_expression", "call_expression":
+		if w.gqlFound {
+			break
+		}
+		fn := n.ChildByFieldName("constructor")
+		if fn == nil {
+			fn = n.ChildByFieldName("function")
+		}
+		if fn == nil {
+			break
+		}
+		name := fn.Content(w.src)
+		name = name[strings.LastIndex(name, ".")+1:]
+		cfg := w.firstArg(n)
+		if cfg == nil || cfg.Type() != "object" {
+			break
+		}
+		switch name {
+		case "ApolloClient", "HttpLink", "BatchHttpLink", "createHttpLink", "createUploadLink":
+			if uri := w.literal(w.objectPropertyNode(cfg, "uri")); uri != "" {
+				w.gqlEndpoint, w.gqlFound = uri, true
+				w.gqlHeaders = w.literalHeaders(w.objectPropertyNode(cfg, "headers"))
+			}
+		case "createClient", "Client":
+			// urql, other createClient(...) helpers don't have exchanges
+			if url := w.literal(w.objectPropertyNode(cfg, "url")); url != "" && (w.objectPropertyNode(cfg, "exchanges") != nil || strings.Contains(url, "graphql")) {
+				w.gqlEndpoint, w.gqlFound = url, true
+				w.gqlHeaders = w.literalHeaders(w.objectPropertyNode(w.objectPropertyNode(cfg, "fetchOptions"), "headers"))
+			}
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		w.collectClients(n.NamedChild(i))
+	}
+}
+
+// decodeClient returns the request of a library call, nil if call is none
+func (w *walker) decodeClient(call *sitter.Node) *clientCall {
+	fn := call.ChildByFieldName("function")
+	argsNode := call.ChildByFieldName("arguments")
+	if fn == nil || argsNode == nil {
+		return nil
+	}
+	args := w.namedArgs(call)
+
+	if fn.Type() == "identifier" {
+		name := fn.Content(w.src)
+		// gql`query Users { ... }` of Apollo and urql
+		if name == "gql" && argsNode.Type() == "template_string" {
+			return w.decodeGraphQL(argsNode)
+		}
+		switch lib := w.clientAliases[name]; lib {
+		case "ky", "got", "ofetch":
+			return w.decodeOptionsCall(lib, "", args)
+		case "superagent":
+			// superagent(url) or superagent(method, url)
+			c := &clientCall{prim: "superagent", url: w.literal(arg(args, 0))}
+			if len(args) >= 2 {
+				c.method, c.url = c.url, w.literal(args[1])
+			}
+			return w.decodeSuperagent(c, nil, w.climbChain(call))
+		case "wretch":
+			c := w.wretchRoot(args)
+			if !w.applyWretch(c, w.climbChain(call)) {
+				return nil
+			}
+			return finish(c)
+		}
+		return nil
+	}
+
+	if fn.Type() != "member_expression" {
+		return nil
+	}
+	path := w.memberPath(fn)
+	if len(path) < 2 {
+		return nil
+	}
+	obj, method := strings.Join(path[:len(path)-1], "."), path[len(path)-1]
+
+	switch lib := w.clientAliases[obj]; {
+	case (lib == "ky" || lib == "got") && optionMethods[method]:
+		return w.decodeOptionsCall(lib, method, args)
+	case lib == "ofetch" && method == "raw":
+		return w.decodeOptionsCall(lib, "", args)
+	case lib == "superagent":
+		m, ok := superagentMethods[method]
+		if !ok {
+			return nil
+		}
+		// superagent.get(url [, data] [, callback])
+		var data *sitter.Node
+		if d := arg(args, 1); d != nil && !isCallback(d) {
+			data = d
+		}
+		return w.decodeSuperagent(&clientCall{prim: "superagent." + method, method: m, url: w.literal(arg(args, 0))}, data, w.climbChain(call))
+	}
+
+	// api.url("/users").get() on a wretch base
+	if c := w.wretchBase(obj); c != nil {
+		if !w.applyWretch(c, append([]chainLink{{name: method, args: args}}, w.climbChain(call)...)) {
+			return nil
+		}
+		return finish(c)
+	}
+
+	// http.get(url, res => ...) is Node's callback API, HttpClient never takes one
+	if w.angularClient(path[len(path)-2]) && angularMethods[method] && !callbackLast(args) {
+		return w.decodeAngular(method, args)
+	}
+	return nil
+}
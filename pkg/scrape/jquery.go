@@ -0,0 +1,199 @@
+package scrape
+
+import (
+	neturl "net/url"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Settings of $.ajax and $.ajaxSetup
+type jqSettings struct {
+	url, method, ctype, dataType string
+	data                         *sitter.Node
+	headers                      []structs.HARNameValue
+}
+
+// dataType sets the Accept header
+var jqAccept = map[string]string{
+	"json":   "application/json, text/javascript, */*; q=0.01",
+	"jsonp":  "text/javascript, application/javascript, application/ecmascript, application/x-ecmascript, */*; q=0.01",
+	"script": "text/javascript, application/javascript, application/ecmascript, application/x-ecmascript, */*; q=0.01",
+	"xml":    "application/xml, text/xml, */*; q=0.01",
+	"html":   "text/html, */*; q=0.01",
+	"text":   "text/plain, */*; q=0.01",
+}
+
+func (w *walker) readSettings(objNode *sitter.Node) jqSettings {
+	s := jqSettings{}
+	objNode = w.valueOf(objNode, 0)
+	if objNode == nil || objNode.Type() != "object" {
+		return s
+	}
+	s.url = w.extractObjectProperty(objNode, "url")
+	s.method = w.extractObjectProperty(objNode, "method")
+	if s.method == "" {
+		s.method = w.extractObjectProperty(objNode, "type")
+	}
+	s.ctype = w.extractString(w.objectPropertyNode(objNode, "contentType"))
+	s.dataType = w.extractString(w.objectPropertyNode(objNode, "dataType"))
+	s.data = w.objectPropertyNode(objNode, "data")
+	s.headers = w.extractHeaders(objNode)
+	return s
+}
+
+// jqData serializes the data argument like jQuery does: objects become a query string
+func (r *resolver) jqData(node *sitter.Node) string {
+	node = r.valueOf(node, 0)
+	if node == nil {
+		return ""
+	}
+	switch node.Type() {
+	case "string", "template_string":
+		return r.extractString(node)
+	case "object":
+		var parts []string
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			child := node.NamedChild(i)
+			if child.Type() == "shorthand_property_identifier" {
+				parts = append(parts, neturl.QueryEscape(child.Content(r.src))+"=")
+				continue
+			}
+			if child.Type() != "pair" {
+				continue
+			}
+			key := strings.Trim(child.ChildByFieldName("key").Content(r.src), `"'`)
+			value := ""
+			if v := r.valueOf(child.ChildByFieldName("value"), 0); v != nil {
+				switch v.Type() {
+				case "true", "false":
+					value = v.Content(r.src)
+				default:
+					value, _ = r.constString(v, 0)
+				}
+			}
+			parts = append(parts, neturl.QueryEscape(key)+"="+neturl.QueryEscape(value))
+		}
+		return strings.Join(parts, "&")
+	case "call_expression":
+		// JSON.stringify(...) and friends, GuessContentType recognizes them
+		return node.Content(r.src)
+	}
+	return ""
+}
+
+// isCallback reports whether an argument is a success/complete handler instead of data
+func isCallback(node *sitter.Node) bool {
+	switch node.Type() {
+	case "function", "function_expression", "arrow_function", "identifier", "member_expression":
+		return true
+	}
+	return false
+}
+
+// decodeJQuery returns method, URL, body, Content-Type and headers of a jQuery call
+func (w *walker) decodeJQuery(prim string, args []*sitter.Node) (string, string, string, string, []structs.HARNameValue) {
+	var s jqSettings
+	switch prim {
+	case "$.ajax":
+		// $.ajax(settings) or $.ajax(url, settings)
+		if len(args) >= 1 && args[0].Type() == "object" {
+			s = w.readSettings(args[0])
+		} else if len(args) >= 1 {
+			if len(args) >= 2 {
+				s = w.readSettings(args[1])
+			}
+			s.url = w.extractString(args[0])
+		}
+	case "$.get", "$.post", "$.getJSON", "$.getScript":
+		// $.get(settings) or $.get(url [, data] [, success] [, dataType])
+		if len(args) >= 1 && args[0].Type() == "object" {
+			s = w.readSettings(args[0])
+		} else if len(args) >= 1 {
+			s.url = w.extractString(args[0])
+			rest := args[1:]
+			if len(rest) >= 1 && !isCallback(rest[0]) {
+				s.data = rest[0]
+				rest = rest[1:]
+			}
+			if n := len(rest); n >= 1 && rest[n-1].Type() == "string" {
+				s.dataType = w.extractString(rest[n-1])
+			}
+		}
+		switch prim {
+		case "$.post":
+			s.method = "POST"
+		case "$.getJSON":
+			s.method, s.dataType = "GET", "json"
+		case "$.getScript":
+			s.method, s.dataType = "GET", "script"
+		default:
+			if s.method == "" {
+				s.method = "GET"
+			}
+		}
+	case "$.load":
+		// $(sel).load("url #fragment" [, data] [, complete]), object data switches to POST
+		if len(args) >= 1 {
+			s.url, _, _ = strings.Cut(strings.TrimSpace(w.extractString(args[0])), " ")
+		}
+		s.method = "GET"
+		s.dataType = "html"
+		if len(args) >= 2 && !isCallback(args[1]) {
+			s.data = args[1]
+			if args[1].Type() == "object" {
+				s.method = "POST"
+			}
+		}
+	}
+
+	// Fill in $.ajaxSetup defaults, headers of the call win
+	if s.url == "" {
+		s.url = w.ajaxDefaults.url
+	}
+	if s.method == "" {
+		s.method = w.ajaxDefaults.method
+	}
+	if s.method == "" {
+		s.method = "GET"
+	}
+	if s.ctype == "" {
+		s.ctype = w.ajaxDefaults.ctype
+	}
+	if s.dataType == "" {
+		s.dataType = w.ajaxDefaults.dataType
+	}
+	if s.data == nil {
+		s.data = w.ajaxDefaults.data
+	}
+	hdrs := []structs.HARNameValue{}
+	for _, h := range w.ajaxDefaults.headers {
+		if !helper.HasHeader(s.headers, h.Name) {
+			hdrs = append(hdrs, h)
+		}
+	}
+	hdrs = append(hdrs, s.headers...)
+	if accept, ok := jqAccept[strings.ToLower(s.dataType)]; ok && !helper.HasHeader(hdrs, "Accept") {
+		hdrs = append(hdrs, structs.HARNameValue{Name: "Accept", Value: accept})
+	}
+	if !helper.HasHeader(hdrs, "X-Requested-With") {
+		hdrs = append(hdrs, structs.HARNameValue{Name: "X-Requested-With", Value: "XMLHttpRequest"})
+	}
+
+	method := strings.ToUpper(s.method)
+	data := w.jqData(s.data)
+	if data == "" {
+		return method, s.url, "", s.ctype, hdrs
+	}
+	// GET and HEAD append the data to the URL
+	if method == "GET" || method == "HEAD" {
+		return method, appendQuery(s.url, data), "", s.ctype, hdrs
+	}
+	ctype := s.ctype
+	if ctype == "" {
+		ctype = "application/x-www-form-urlencoded; charset=UTF-8"
+	}
+	return method, s.url, data, ctype, hdrs
+}
@@ -0,0 +1,469 @@
+package scrape
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Resolution depth, guards against const a = b, b = a
+const maxResolveDepth = 16
+
+// A const/let/var binding or a parameter (value nil), mutable when it is assigned again
+type binding struct {
+	value   *sitter.Node
+	mutable bool
+}
+
+type scopeKey struct {
+	start, end uint32
+	kind       string
+}
+
+func keyOf(n *sitter.Node) scopeKey {
+	return scopeKey{n.StartByte(), n.EndByte(), n.Type()}
+}
+
+var (
+	// const and let are block scoped, so are the bindings of for...of/in heads and catch parameters
+	blockScopes = map[string]bool{
+		"program": true, "statement_block": true, "for_statement": true, "for_in_statement": true, "switch_body": true,
+		"catch_clause": true,
+	}
+	// var and parameters belong to the function
+	functionScopes = map[string]bool{
+		"program": true, "function_declaration": true, "function": true, "function_expression": true, "arrow_function": true,
+		"method_definition": true, "generator_function": true, "generator_function_declaration": true,
+	}
+)
+
+// resolver follows identifiers and property accesses of one script to the constants they are bound to
+type resolver struct {
+	src      []byte
+	root     *sitter.Node
+	bindings map[scopeKey]map[string]*binding
+	bound    map[string]bool // names declared anywhere, globals skip the scope walk
+}
+
+func newResolver(src []byte, root *sitter.Node) *resolver {
+	r := &resolver{
+		src:      src,
+		root:     root,
+		bindings: map[scopeKey]map[string]*binding{},
+		bound:    map[string]bool{},
+	}
+	// Identifiers assigned after their declaration, resolved once every binding is known
+	var assigned []*sitter.Node
+	r.collectBindings(root, &assigned)
+	// Reassigned bindings can't be resolved
+	for _, ident := range assigned {
+		if b := r.lookup(ident); b != nil {
+			b.mutable = true
+		}
+	}
+	return r
+}
+
+func (r *resolver) enclosingScope(n *sitter.Node, scopes map[string]bool) *sitter.Node {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if scopes[p.Type()] {
+			return p
+		}
+	}
+	return r.root
+}
+
+func (r *resolver) bind(scope *sitter.Node, name string, value *sitter.Node) {
+	k := keyOf(scope)
+	if r.bindings[k] == nil {
+		r.bindings[k] = map[string]*binding{}
+	}
+	if _, ok := r.bindings[k][name]; ok {
+		// var declared twice
+		r.bindings[k][name].mutable = true
+		return
+	}
+	r.bindings[k][name] = &binding{value: value}
+	r.bound[name] = true
+}
+
+// bindPattern binds the names of a destructuring pattern, their values are unknown
+func (r *resolver) bindPattern(scope, pattern *sitter.Node) {
+	switch pattern.Type() {
+	case "identifier", "shorthand_property_identifier_pattern":
+		r.bind(scope, pattern.Content(r.src), nil)
+		return
+	case "assignment_pattern", "object_assignment_pattern":
+		// function f(a = base), the default is a reference, not a binding
+		if left := pattern.ChildByFieldName("left"); left != nil {
+			r.bindPattern(scope, left)
+		}
+		return
+	}
+	for i := 0; i < int(pattern.NamedChildCount()); i++ {
+		r.bindPattern(scope, pattern.NamedChild(i))
+	}
+}
+
+// lookup returns the binding an identifier refers to, nil for globals
+func (r *resolver) lookup(ident *sitter.Node) *binding {
+	name := ident.Content(r.src)
+	if !r.bound[name] {
+		return nil
+	}
+	for p := ident.Parent(); p != nil; p = p.Parent() {
+		if t := p.Type(); !blockScopes[t] && !functionScopes[t] {
+			continue
+		}
+		if b, ok := r.bindings[keyOf(p)][name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (r *resolver) collectBindings(n *sitter.Node, assigned *[]*sitter.Node) {
+	switch n.Type() {
+	case "lexical_declaration", "variable_declaration":
+		scope := r.enclosingScope(n, blockScopes)
+		if n.Type() == "variable_declaration" {
+			scope = r.enclosingScope(n, functionScopes)
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			decl := n.NamedChild(i)
+			if decl.Type() != "variable_declarator" {
+				continue
+			}
+			name := decl.ChildByFieldName("name")
+			if name == nil {
+				continue
+			}
+			if name.Type() == "identifier" {
+				r.bind(scope, name.Content(r.src), decl.ChildByFieldName("value"))
+			} else {
+				r.bindPattern(scope, name)
+			}
+		}
+	case "for_in_statement":
+		// for (const x of list), x takes another value every iteration
+		left := n.ChildByFieldName("left")
+		if left == nil {
+			break
+		}
+		switch kind := n.ChildByFieldName("kind"); {
+		case kind == nil:
+			// for (x of list) assigns an existing binding
+			if left.Type() == "identifier" {
+				*assigned = append(*assigned, left)
+			}
+		case kind.Type() == "var":
+			r.bindPattern(r.enclosingScope(n, functionScopes), left)
+		default:
+			r.bindPattern(n, left)
+		}
+	case "catch_clause":
+		if p := n.ChildByFieldName("parameter"); p != nil {
+			r.bindPattern(n, p)
+		}
+	case "formal_parameters":
+		if fn := n.Parent(); fn != nil {
+			r.bindPattern(fn, n)
+		}
+	case "arrow_function":
+		if p := n.ChildByFieldName("parameter"); p != nil {
+			r.bindPattern(n, p)
+		}
+	case "assignment_expression", "augmented_assignment_expression":
+		if left := n.ChildByFieldName("left"); left != nil && left.Type() == "identifier" {
+			*assigned = append(*assigned, left)
+		}
+	case "update_expression":
+		if arg := n.ChildByFieldName("argument"); arg != nil && arg.Type() == "identifier" {
+			*assigned = append(*assigned, arg)
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		r.collectBindings(n.NamedChild(i), assigned)
+	}
+}
+
+// propertyValue returns the value of key in an object literal, shorthand properties resolve to their identifier
+func (r *resolver) propertyValue(obj *sitter.Node, key string) *sitter.Node {
+	for i := 0; i < int(obj.NamedChildCount()); i++ {
+		child := obj.NamedChild(i)
+		switch child.Type() {
+		case "pair":
+			if k := child.ChildByFieldName("key"); k != nil && strings.Trim(k.Content(r.src), `"'`) == key {
+				return child.ChildByFieldName("value")
+			}
+		case "shorthand_property_identifier":
+			if child.Content(r.src) == key {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// valueOf follows identifiers and property accesses (config.endpoints.users, ENDPOINTS[0]) to the
+// expression they are bound to. Functions and calls are not followed, node is returned when nothing resolves.
+func (r *resolver) valueOf(node *sitter.Node, depth int) *sitter.Node {
+	if node == nil || depth > maxResolveDepth {
+		return node
+	}
+	var next *sitter.Node
+	switch node.Type() {
+	case "parenthesized_expression":
+		if node.NamedChildCount() == 1 {
+			next = node.NamedChild(0)
+		}
+	case "identifier", "shorthand_property_identifier":
+		if b := r.lookup(node); b != nil && !b.mutable {
+			next = b.value
+		}
+	case "member_expression", "subscript_expression":
+		obj := r.valueOf(node.ChildByFieldName("object"), depth+1)
+		key := ""
+		if prop := node.ChildByFieldName("property"); prop != nil {
+			key = prop.Content(r.src)
+		} else if idx := node.ChildByFieldName("index"); idx != nil {
+			key, _ = r.constString(idx, depth+1)
+		}
+		switch {
+		case obj == nil || key == "":
+		case obj.Type() == "object":
+			next = r.propertyValue(obj, key)
+		case obj.Type() == "array":
+			if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < int(obj.NamedChildCount()) {
+				next = obj.NamedChild(i)
+			}
+		}
+	}
+	if next == nil {
+		return node
+	}
+	switch next.Type() {
+	case "function", "function_expression", "arrow_function", "class", "call_expression", "await_expression":
+		// JSON.stringify(...) is the one call worth following
+		if fn := next.ChildByFieldName("function"); fn == nil || fn.Content(r.src) != "JSON.stringify" {
+			return node
+		}
+	}
+	return r.valueOf(next, depth+1)
+}
+
+// templateText substitutes the ${...} parts of a template literal that resolve, complete is false
+// when some are left as they are
+func (r *resolver) templateText(node *sitter.Node, depth int) (string, bool) {
+	var b strings.Builder
+	complete := true
+	prev := node.StartByte() + 1
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		sub := node.NamedChild(i)
+		if sub.Type() != "template_substitution" {
+			continue
+		}
+		b.Write(r.src[prev:sub.StartByte()])
+		if sub.NamedChildCount() == 1 {
+			if v, ok := r.constString(sub.NamedChild(0), depth+1); ok {
+				b.WriteString(v)
+				prev = sub.EndByte()
+				continue
+			}
+		}
+		complete = false
+		b.Write(r.src[sub.StartByte():sub.EndByte()])
+		prev = sub.EndByte()
+	}
+	if end := node.EndByte() - 1; end > prev {
+		b.Write(r.src[prev:end])
+	}
+	return b.String(), complete
+}
+
+// operand resolves one part of a concatenation, parts that don't resolve are kept as ${...}
+// like in a template literal
+func (r *resolver) operand(node *sitter.Node, depth int) (string, bool) {
+	v, ok := r.constString(node, depth)
+	if !ok && v == "" && node != nil {
+		v = "${" + node.Content(r.src) + "}"
+	}
+	return v, ok
+}
+
+// constString evaluates string expressions: literals, bindings, properties, +, concat, join and
+// ternaries (the first branch that resolves). ok is false when parts are left as ${...}.
+func (r *resolver) constString(node *sitter.Node, depth int) (string, bool) {
+	if node == nil || depth > maxResolveDepth {
+		return "", false
+	}
+	node = r.valueOf(node, depth)
+	switch node.Type() {
+	case "string":
+		return strings.Trim(node.Content(r.src), `"'`), true
+	case "template_string":
+		return r.templateText(node, depth)
+	case "number":
+		return node.Content(r.src), true
+	case "binary_expression":
+		op := node.ChildByFieldName("operator")
+		if op == nil || op.Type() != "+" {
+			return "", false
+		}
+		l, lok := r.operand(node.ChildByFieldName("left"), depth+1)
+		rv, rok := r.operand(node.ChildByFieldName("right"), depth+1)
+		return l + rv, lok && rok
+	case "ternary_expression":
+		if v, ok := r.constString(node.ChildByFieldName("consequence"), depth+1); ok {
+			return v, true
+		}
+		return r.constString(node.ChildByFieldName("alternative"), depth+1)
+	case "call_expression":
+		fn := node.ChildByFieldName("function")
+		if fn == nil || fn.Type() != "member_expression" {
+			return "", false
+		}
+		var args []*sitter.Node
+		if argsNode := node.ChildByFieldName("arguments"); argsNode != nil {
+			for i := 0; i < int(argsNode.NamedChildCount()); i++ {
+				args = append(args, argsNode.NamedChild(i))
+			}
+		}
+		obj := fn.ChildByFieldName("object")
+		switch fn.ChildByFieldName("property").Content(r.src) {
+		case "concat":
+			// "/api".concat("/users", id)
+			s, ok := r.operand(obj, depth+1)
+			for _, a := range args {
+				v, vok := r.operand(a, depth+1)
+				s, ok = s+v, ok && vok
+			}
+			return s, ok
+		case "join":
+			// [BASE, "users"].join("/")
+			arr := r.valueOf(obj, depth+1)
+			if arr == nil || arr.Type() != "array" {
+				return "", false
+			}
+			sep := ","
+			if len(args) > 0 {
+				v, ok := r.constString(args[0], depth+1)
+				if !ok {
+					return "", false
+				}
+				sep = v
+			}
+			parts := make([]string, 0, arr.NamedChildCount())
+			complete := true
+			for i := 0; i < int(arr.NamedChildCount()); i++ {
+				v, ok := r.operand(arr.NamedChild(i), depth+1)
+				parts = append(parts, v)
+				complete = complete && ok
+			}
+			return strings.Join(parts, sep), complete
+		}
+	}
+	return "", false
+}
+
+// --- helpers ---
+
+func (r *resolver) extractString(node *sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	switch node.Type() {
+	case "string":
+		return strings.Trim(node.Content(r.src), `"'`)
+	case "template_string":
+		// Substitutions that can't be resolved stay as ${...}
+		text, _ := r.templateText(node, 0)
+		return text
+	case "object":
+		// Raw object literal in place of string
+		return node.Content(r.src)
+	}
+	// Constants, concatenations, config.endpoints.users, ...
+	v, _ := r.constString(node, 0)
+	return v
+}
+
+func (r *resolver) extractObjectProperty(objNode *sitter.Node, propName string) string {
+	objNode = r.valueOf(objNode, 0)
+	if objNode == nil || (objNode.Type() != "object" && objNode.Type() != "object_pattern") {
+		return ""
+	}
+	for i := 0; i < int(objNode.NamedChildCount()); i++ {
+		child := objNode.NamedChild(i)
+		if child.Type() == "pair" {
+			keyNode := child.ChildByFieldName("key")
+			valueNode := r.valueOf(child.ChildByFieldName("value"), 0)
+			if keyNode != nil && strings.Trim(keyNode.Content(r.src), `"'`) == propName {
+				return r.extractString(valueNode)
+			}
+			// Special: headers.{Content-Type}
+			if keyNode != nil && strings.Trim(keyNode.Content(r.src), `"'`) == "headers" && valueNode.Type() == "object" {
+				if v := r.extractObjectProperty(valueNode, propName); v != "" {
+					return v
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Literal headers of the "headers" property of a config object, e.g. fetch(url, { headers: {...} })
+func (r *resolver) extractHeaders(objNode *sitter.Node) []structs.HARNameValue {
+	out := []structs.HARNameValue{}
+	objNode = r.valueOf(objNode, 0)
+	if objNode == nil || objNode.Type() != "object" {
+		return out
+	}
+	for i := 0; i < int(objNode.NamedChildCount()); i++ {
+		child := objNode.NamedChild(i)
+		if child.Type() != "pair" {
+			continue
+		}
+		keyNode := child.ChildByFieldName("key")
+		valueNode := r.valueOf(child.ChildByFieldName("value"), 0)
+		if keyNode == nil || valueNode == nil || valueNode.Type() != "object" || strings.Trim(keyNode.Content(r.src), `"'`) != "headers" {
+			continue
+		}
+		for j := 0; j < int(valueNode.NamedChildCount()); j++ {
+			h := valueNode.NamedChild(j)
+			if h.Type() != "pair" {
+				continue
+			}
+			name := strings.Trim(h.ChildByFieldName("key").Content(r.src), `"'`)
+			v, ok := r.constString(h.ChildByFieldName("value"), 0)
+			if !ok {
+				continue
+			}
+			out = append(out, structs.HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+// Value node of a property of an object literal
+func (r *resolver) objectPropertyNode(objNode *sitter.Node, propName string) *sitter.Node {
+	objNode = r.valueOf(objNode, 0)
+	if objNode == nil || objNode.Type() != "object" {
+		return nil
+	}
+	if v := r.propertyValue(objNode, propName); v != nil {
+		return r.valueOf(v, 0)
+	}
+	return nil
+}
+
+// constant is like literal but "" unless every part resolves, for header values and credentials
+func (r *resolver) constant(node *sitter.Node) string {
+	v, ok := r.constString(node, 0)
+	if !ok {
+		return ""
+	}
+	return v
+}
@@ -8,22 +8,27 @@ import (
 	"strings"
 	"time"
 
+	"github.com/m-1tZ/reqtrack/pkg/headers"
 	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/scope"
 	"github.com/m-1tZ/reqtrack/pkg/structs"
 	pw "github.com/playwright-community/playwright-go"
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/javascript"
 )
 
-// ScrapeHtml will try to collect inline & external script sources from the current page context.
-// IMPORTANT: it will first try to read document.scripts (so it will NOT navigate if the page is already loaded).
-// If that yields nothing, it falls back to navigating targetURL once.
+// ScrapeRequests navigates page to targetURL and statically analyzes the scripts of every frame,
+// same-origin and cross-origin, including scripts inside open shadow roots. External scripts are
+// fetched through browserCtx when they are in scope. Findings of child frames are resolved against
+// the frame's own URL and carry it in their _frame field.
 func ScrapeRequests(
 	page pw.Page,
 	browserCtx pw.BrowserContext,
 	targetURL string,
 	navTimeout float64,
 	parseTimeout float64,
+	sc *scope.Scope,
+	hdr *headers.Set,
 ) ([]*structs.HAREntry, error) {
 
 	// ---------------------------------------------------------
@@ -41,10 +46,93 @@ func ScrapeRequests(
 	// 	return nil, err
 	// }
 
+	// ---------------------------------------------------------
+	// 2) Walk every frame (same- and cross-origin)
+	// ---------------------------------------------------------
+	var all []*structs.HAREntry
+	for _, frame := range page.Frames() {
+		isMain := frame.ParentFrame() == nil
+
+		scriptList, err := extractScripts(frame)
+		if err != nil {
+			if isMain {
+				return nil, err
+			}
+			log.Printf("Script extraction in frame %s failed: %v", frame.URL(), err)
+			continue
+		}
+
+		combinedScripts := fetchScripts(browserCtx.Request(), scriptList, navTimeout, sc, hdr)
+
+		// ---------------------------------------------------------
+		// 4) Run tree-sitter static JS detection on each JS script
+		// ---------------------------------------------------------
+		var findings []*structs.HAREntry
+		for _, js := range combinedScripts {
+			if js == "" {
+				continue
+			}
+
+			// --- Skip JS > 4 MB ---
+			if len(js) > 4*1024*1024 {
+				log.Printf("skipping JS >4MB (%d bytes)", len(js))
+				continue
+			}
+
+			found, err := findHttpPrimitives(context.Background(), js, parseTimeout)
+			if err != nil {
+				log.Printf("tree-sitter error: %v", err)
+				continue
+			}
+			findings = append(findings, found...)
+		}
+		if isMain {
+			all = append(all, findings...)
+			continue
+		}
+
+		// Relative URLs in a child frame belong to the frame's origin, not to targetURL
+		findings, err = helper.DeduplicateHAREntries(findings, frameBase(frame, targetURL))
+		if err != nil {
+			log.Printf("Error in DeduplicateHAREntries for frame %s: %v", frame.URL(), err)
+			continue
+		}
+		for _, e := range findings {
+			e.Frame = frame.URL()
+		}
+		all = append(all, findings...)
+	}
+
+	// ---------------------------------------------------------
+	// 5) Normalize & dedupe just like your original code
+	// ---------------------------------------------------------
+	// Normalize HAR entries
+	results, err := helper.DeduplicateHAREntries(all, targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error in DeduplicateHAREntries: %w", err)
+	}
+
+	return results, nil
+}
+
+// extractScripts returns the src URL or the inline source of every script of frame, open shadow roots included
+func extractScripts(frame pw.Frame) ([]string, error) {
 	// ---------------------------------------------------------
 	// 2) Extract <script> contents & URLs from DOM
 	// ---------------------------------------------------------
-	rawJSON, err := page.Evaluate(`() => JSON.stringify(Array.from(document.scripts).map(s => s.src || s.textContent || s.innerHTML))`)
+	rawJSON, err := frame.Evaluate(`() => {
+		const out = [];
+		const walk = (root) => {
+			for (const s of root.querySelectorAll('script')) {
+				out.push(s.src || s.textContent || s.innerHTML);
+			}
+			for (const el of root.querySelectorAll('*')) {
+				if (el.shadowRoot) walk(el.shadowRoot);
+			}
+		};
+		walk(document);
+		return JSON.stringify(out);
+	}`)
 	if err != nil {
 		return nil, err
 	}
@@ -59,11 +147,20 @@ func ScrapeRequests(
 	if err := json.Unmarshal([]byte(jsonStr), &scriptList); err != nil {
 		return nil, err
 	}
+	return scriptList, nil
+}
+
+// fetchScripts resolves external scripts to their source, inline scripts are kept as they are
+func fetchScripts(
+	request pw.APIRequestContext,
+	scriptList []string,
+	navTimeout float64,
+	sc *scope.Scope,
+	hdr *headers.Set,
+) []string {
 	// ---------------------------------------------------------
 	// 3) Fetch external JS via Playwright (keeps proxy/headers/cookies)
 	// ---------------------------------------------------------
-	request := browserCtx.Request()
-
 	combinedScripts := make([]string, 0, len(scriptList))
 
 	for _, item := range scriptList {
@@ -72,8 +169,14 @@ func ScrapeRequests(
 		}
 
 		if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+			if !sc.InScope(item) {
+				log.Printf("Skipping out-of-scope external JS %s", item)
+				continue
+			}
+
 			// Fetch through Playwright's network stack
 			resp, err := request.Get(item, pw.APIRequestContextGetOptions{
+				Headers:           hdr.For(item),
 				Timeout:           pw.Float(navTimeout),
 				IgnoreHttpsErrors: pw.Bool(true),
 			})
@@ -92,40 +195,17 @@ func ScrapeRequests(
 			combinedScripts = append(combinedScripts, item)
 		}
 	}
+	return combinedScripts
+}
 
-	// ---------------------------------------------------------
-	// 4) Run tree-sitter static JS detection on each JS script
-	// ---------------------------------------------------------
-	var all []*structs.HAREntry
-	for _, js := range combinedScripts {
-		if js == "" {
-			continue
-		}
-
-		// --- Skip JS > 4 MB ---
-		if len(js) > 4*1024*1024 {
-			log.Printf("skipping JS >4MB (%d bytes)", len(js))
-			continue
-		}
-
-		findings, err := findHttpPrimitives(context.Background(), js, parseTimeout)
-		if err != nil {
-			log.Printf("tree-sitter error: %v", err)
-			continue
-		}
-		all = append(all, findings...)
-	}
-
-	// ---------------------------------------------------------
-	// 5) Normalize & dedupe just like your original code
-	// ---------------------------------------------------------
-	// Normalize HAR entries
-	results, err := helper.DeduplicateHAREntries(all, targetURL)
-	if err != nil {
-		return nil, fmt.Errorf("Error in DeduplicateHAREntries: %w", err)
+// frameBase returns the URL relative findings of frame are resolved against.
+// about:blank and srcdoc frames inherit the origin of the page.
+func frameBase(frame pw.Frame, targetURL string) string {
+	u := frame.URL()
+	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
 	}
-
-	return results, nil
+	return targetURL
 }
 
 // ---- Tree-sitter static JS detection ----
@@ -150,305 +230,364 @@ func findHttpPrimitives(parentCtx context.Context, jsCode string, parseTimeout f
 		tree, err := parser.ParseCtx(ctx, nil, []byte(jsCode))
 		if err != nil {
 			ch <- result{nil, fmt.Errorf("failed to parse JS code: %w", err)}
+			return
 		}
 
-		root := tree.RootNode()
-		var results []*structs.HAREntry
-		src := []byte(jsCode)
+		w := newWalker(ctx, []byte(jsCode), tree.RootNode())
+		// Instances, aliases and receivers are collected before the walk,
+		// bundles often define them after the code that uses them
+		w.collectClients(w.root)
+		w.collectAxios(w.root)
+		w.collectXHR(w.root)
+		w.walk(w.root)
+		w.flushXHR()
+		ch <- result{w.results, nil}
+	}()
+
+	// --- wait for worker ---
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("JS parse timed out")
+	case res := <-ch:
+		return res.val, res.err
+	}
+	//return results, nil
+}
+
+// walker holds the state of the static analysis of one script. The resolver is shared by every
+// decoder, the rest is collected before the walk (instances, aliases, receivers) or during it
+// ($.ajaxSetup defaults, open XMLHttpRequests).
+type walker struct {
+	*resolver
+	ctx     context.Context
+	results []*structs.HAREntry
+
+	// jQuery: defaults of $.ajaxSetup apply to every jQuery call that follows in the source
+	ajaxDefaults jqSettings
+
+	// axios: instances created with axios.create, plus axios and redaxios themselves
+	axiosInstances map[string]*axiosInstance
+
+	// Client libraries: names they are imported under, wretch bases created with
+	// const api = wretch(url).headers(...) and no request method, whether the script shows Angular
+	clientAliases map[string]string
+	wretchBases   map[string]*clientCall
+	angularSeen   bool
+
+	// GraphQL: endpoint and headers of the Apollo HttpLink or urql client, documents are POSTed there
+	gqlEndpoint string
+	gqlHeaders  []structs.HARNameValue
+	gqlFound    bool
+
+	// XMLHttpRequest: receivers assigned new XMLHttpRequest() and requests between open() and send()
+	xhrReceivers map[string]bool
+	pendingXHR   map[string]*xhrRequest
+	xhrSeq       int
+}
 
-		// --- helpers ---
-		extractString := func(node *sitter.Node) string {
-			if node == nil {
-				return ""
+func newWalker(ctx context.Context, src []byte, root *sitter.Node) *walker {
+	w := &walker{
+		resolver: newResolver(src, root),
+		ctx:      ctx,
+		axiosInstances: map[string]*axiosInstance{
+			"axios":    {name: "axios", lib: "axios"},
+			"redaxios": {name: "redaxios", lib: "redaxios"},
+		},
+		clientAliases: make(map[string]string, len(defaultClientAliases)),
+		wretchBases:   map[string]*clientCall{},
+		gqlEndpoint:   "/graphql",
+		xhrReceivers:  map[string]bool{},
+		pendingXHR:    map[string]*xhrRequest{},
+	}
+	for name, lib := range defaultClientAliases {
+		w.clientAliases[name] = lib
+	}
+	return w
+}
+
+// --- AST walker ---
+func (w *walker) walk(node *sitter.Node) {
+	// abort if context expired
+	select {
+	case <-w.ctx.Done():
+		return
+	default:
+	}
+
+	if node == nil {
+		return
+	}
+
+	if node.Type() == "call_expression" {
+		w.visitCall(node)
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		w.walk(node.Child(i))
+	}
+}
+
+// visitCall adds the request a call expression sends, if any
+func (w *walker) visitCall(node *sitter.Node) {
+	src := w.src
+	funcNode := node.ChildByFieldName("function")
+	if funcNode == nil {
+		return
+	}
+	funcName := funcNode.Content(src)
+	prim := ""
+	isFetch := false
+	var inst *axiosInstance
+
+	switch funcNode.Type() {
+	case "identifier":
+		if funcName == "fetch" {
+			prim = "fetch"
+			isFetch = true
+		}
+		// axios(...) or a callable instance api(...)
+		if i, ok := w.axiosInstances[funcName]; ok {
+			prim = i.lib
+			inst = i
+		}
+	case "member_expression":
+		obj := funcNode.ChildByFieldName("object")
+		prop := funcNode.ChildByFieldName("property")
+		if obj != nil && prop != nil {
+			// axios.<method>() and <instance>.<method>()
+			if i, ok := w.axiosInstances[strings.Join(w.memberPath(obj), ".")]; ok && axiosMethods[prop.Content(src)] {
+				prim = i.lib + "." + prop.Content(src)
+				inst = i
 			}
-			switch node.Type() {
-			case "string":
-				return strings.Trim(node.Content([]byte(jsCode)), `"'`)
-			case "template_string":
-				return strings.Trim(node.Content([]byte(jsCode)), "`")
-			case "object":
-				// Raw object literal in place of string
-				return node.Content([]byte(jsCode))
+			// $.ajax(...), $.get(...), ... and the jQuery.* spelling
+			if obj.Content(src) == "$" || obj.Content(src) == "jQuery" {
+				switch prop.Content(src) {
+				case "ajax", "get", "post", "getJSON", "getScript":
+					prim = "$." + prop.Content(src)
+				case "ajaxSetup":
+					if argsNode := node.ChildByFieldName("arguments"); argsNode != nil && argsNode.NamedChildCount() > 0 {
+						w.ajaxDefaults = w.readSettings(argsNode.NamedChild(0))
+					}
+				}
+			}
+			// $(selector).load(url)
+			if obj.Type() == "call_expression" && prop.Content(src) == "load" {
+				if fn := obj.ChildByFieldName("function"); fn != nil && (fn.Content(src) == "$" || fn.Content(src) == "jQuery") {
+					prim = "$.load"
+				}
 			}
-			return ""
+			// XMLHttpRequest: open, setRequestHeader and send on the same receiver make one request
+			switch prop.Content(src) {
+			case "open", "setRequestHeader", "send":
+				if recv := w.memberPath(obj); recv != nil {
+					w.handleXHR(strings.Join(recv, "."), prop.Content(src), w.namedArgs(node))
+				}
+			}
+		}
+	}
+
+	// Angular HttpClient, ky, got, ofetch, superagent, wretch and gql documents
+	var call *clientCall
+	if prim == "" {
+		if call = w.decodeClient(node); call != nil {
+			prim = call.prim
 		}
+	}
 
-		var extractObjectProperty func(objNode *sitter.Node, propName string) string
-		extractObjectProperty = func(objNode *sitter.Node, propName string) string {
-			if objNode == nil || (objNode.Type() != "object" && objNode.Type() != "object_pattern") {
-				return ""
+	if prim == "" {
+		return
+	}
+	argsNode := node.ChildByFieldName("arguments")
+	var url, method, ctype, body string
+	var config *sitter.Node // object holding headers
+	var reqHeaders []structs.HARNameValue
+
+	if argsNode != nil {
+		// Arguments bound to constants are read through their value
+		args := []*sitter.Node{}
+		for i := 0; i < int(argsNode.NamedChildCount()); i++ {
+			args = append(args, w.valueOf(argsNode.NamedChild(i), 0))
+		}
+
+		// --- fetch() ---
+		if isFetch {
+			if len(args) >= 1 {
+				url = w.extractString(args[0])
 			}
-			for i := 0; i < int(objNode.NamedChildCount()); i++ {
-				child := objNode.NamedChild(i)
-				if child.Type() == "pair" {
-					keyNode := child.ChildByFieldName("key")
-					valueNode := child.ChildByFieldName("value")
-					if keyNode != nil && strings.Trim(keyNode.Content([]byte(jsCode)), `"'`) == propName {
-						return extractString(valueNode)
-					}
-					// Special: headers.{Content-Type}
-					if keyNode != nil && strings.Trim(keyNode.Content([]byte(jsCode)), `"'`) == "headers" && valueNode.Type() == "object" {
-						return extractObjectProperty(valueNode, propName)
-					}
+			if len(args) >= 2 && args[1].Type() == "object" {
+				method = w.extractObjectProperty(args[1], "method")
+				ctype = w.extractObjectProperty(args[1], "Content-Type")
+				body = w.extractObjectProperty(args[1], "body")
+				// JSON.stringify(...), new FormData(...), GuessContentType recognizes them
+				if b := w.objectPropertyNode(args[1], "body"); body == "" && b != nil && (b.Type() == "call_expression" || b.Type() == "new_expression") {
+					body = b.Content(src)
 				}
+				config = args[1]
 			}
-			return ""
 		}
 
-		// --- AST walker ---
-		var walk func(node *sitter.Node)
-		walk = func(node *sitter.Node) {
-			// abort if context expired
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
+		// --- axios and axios.create instances: axios(...), api.get(...), api.post(...) ---
+		if inst != nil {
+			method, url, body, ctype, reqHeaders = w.decodeAxios(strings.TrimPrefix(prim, inst.lib+"."), args, inst)
+		}
 
-			if node == nil {
-				return
-			}
+		// --- jQuery: $.ajax, $.get, $.post, $.getJSON, $.getScript, $(sel).load ---
+		if strings.HasPrefix(prim, "$.") {
+			method, url, body, ctype, reqHeaders = w.decodeJQuery(prim, args)
+		}
+
+		// --- HTTP client libraries ---
+		if call != nil {
+			method, url, body, ctype, reqHeaders = call.method, call.url, call.body, call.ctype, call.headers
+		}
+
+		if body == "" && len(args) >= 2 && !strings.HasPrefix(prim, "$.") && inst == nil && call == nil {
+			bodyNode := args[1]
+
+			switch bodyNode.Type() {
+
+			case "object":
+				ctype = "application/json"
+
+			case "array":
+				ctype = "application/json"
 
-			if node.Type() == "call_expression" {
-				funcNode := node.ChildByFieldName("function")
-				if funcNode != nil {
-					funcName := funcNode.Content(src)
-					prim := ""
-					isFetch := false
-					isXHROpen := false
-					isXHRSend := false
-
-					switch funcNode.Type() {
-					case "identifier":
-						if funcName == "fetch" {
-							prim = "fetch"
-							isFetch = true
-						}
-						if funcName == "axios" {
-							prim = "axios"
-						}
-					case "member_expression":
-						obj := funcNode.ChildByFieldName("object")
-						prop := funcNode.ChildByFieldName("property")
-						if obj != nil && prop != nil {
-							// axios.<method>()
-							if obj.Content(src) == "axios" {
-								prim = "axios." + prop.Content(src)
-							}
-							// $.ajax(...)
-							if obj.Content(src) == "$" && prop.Content(src) == "ajax" {
-								prim = "$.ajax"
-							}
-							// XMLHttpRequest.open/send(...)
-							if prop.Content(src) == "open" {
-								prim = "XMLHttpRequest.open"
-								isXHROpen = true
-							}
-							if prop.Content(src) == "send" {
-								prim = "XMLHttpRequest.send"
-								isXHRSend = true
-							}
-						}
+			case "call_expression":
+				fn := bodyNode.ChildByFieldName("function")
+				if fn != nil {
+					fname := fn.Content(src)
+
+					if fname == "JSON.stringify" {
+						ctype = "application/json"
+					}
+					if fname == "FormData" {
+						ctype = "multipart/form-data"
+					}
+					if fname == "URLSearchParams" {
+						ctype = "application/x-www-form-urlencoded"
 					}
+					if fname == "atob" {
+						ctype = "application/octet-stream"
+					}
+				}
 
-					if prim != "" {
-						argsNode := node.ChildByFieldName("arguments")
-						var url, method, ctype, body string
-
-						if argsNode != nil {
-							args := []*sitter.Node{}
-							for i := 0; i < int(argsNode.NamedChildCount()); i++ {
-								args = append(args, argsNode.NamedChild(i))
-							}
-
-							// --- fetch() ---
-							if isFetch {
-								if len(args) >= 1 {
-									url = extractString(args[0])
-								}
-								if len(args) >= 2 && args[1].Type() == "object" {
-									method = extractObjectProperty(args[1], "method")
-									ctype = extractObjectProperty(args[1], "Content-Type")
-									body = extractObjectProperty(args[1], "body")
-								}
-							}
-
-							// --- XMLHttpRequest.open(method, url) ---
-							if isXHROpen {
-								if len(args) >= 2 {
-									method = extractString(args[0])
-									url = extractString(args[1])
-								}
-							}
-
-							// --- XMLHttpRequest.send(body) ---
-							if isXHRSend && len(args) >= 1 {
-								body = extractString(args[0])
-							}
-
-							// --- axios.post(...) / axios.get(...) etc. ---
-							if strings.HasPrefix(prim, "axios.") {
-								if len(args) >= 1 {
-									url = extractString(args[0])
-								}
-								if len(args) >= 2 {
-									method = strings.ToUpper(strings.TrimPrefix(prim, "axios."))
-
-									// Second argument is usually the body
-									if args[1].Type() == "object" || args[1].Type() == "array" {
-										body = args[1].Content(src)
-									}
-
-									// Third argument may be config
-									if len(args) >= 3 && args[2].Type() == "object" {
-										ctype = extractObjectProperty(args[2], "Content-Type")
-									}
-								}
-							}
-
-							// --- axios({ url, method, data, headers }) ---
-							if prim == "axios" {
-								if len(args) >= 1 && args[0].Type() == "object" {
-									url = extractObjectProperty(args[0], "url")
-									method = extractObjectProperty(args[0], "method")
-									ctype = extractObjectProperty(args[0], "Content-Type")
-									body = extractObjectProperty(args[0], "data")
-								}
-								if len(args) >= 2 && args[1].Type() == "object" {
-									// axios(url, config)
-									url = extractString(args[0])
-									method = extractObjectProperty(args[1], "method")
-									ctype = extractObjectProperty(args[1], "Content-Type")
-									body = extractObjectProperty(args[1], "data")
-								}
-							}
-
-							// --- $.ajax({...}) ---
-							if prim == "$.ajax" && len(args) >= 1 && args[0].Type() == "object" {
-								url = extractObjectProperty(args[0], "url")
-								method = extractObjectProperty(args[0], "method")
-								if method == "" {
-									method = extractObjectProperty(args[0], "type")
-								}
-								ctype = extractObjectProperty(args[0], "Content-Type")
-								body = extractObjectProperty(args[0], "data")
-							}
-
-							if body == "" && len(args) >= 2 {
-								bodyNode := args[1]
-
-								switch bodyNode.Type() {
-
-								case "object":
-									ctype = "application/json"
-
-								case "array":
-									ctype = "application/json"
-
-								case "call_expression":
-									fn := bodyNode.ChildByFieldName("function")
-									if fn != nil {
-										fname := fn.Content(src)
-
-										if fname == "JSON.stringify" {
-											ctype = "application/json"
-										}
-										if fname == "FormData" {
-											ctype = "multipart/form-data"
-										}
-										if fname == "URLSearchParams" {
-											ctype = "application/x-www-form-urlencoded"
-										}
-										if fname == "atob" {
-											ctype = "application/octet-stream"
-										}
-									}
-
-								case "new_expression":
-									ctor := bodyNode.ChildByFieldName("constructor")
-									if ctor != nil {
-										cname := ctor.Content(src)
-										if cname == "FormData" {
-											ctype = "multipart/form-data"
-										}
-										if cname == "Blob" || cname == "File" {
-											ctype = "application/octet-stream"
-										}
-									}
-								}
-							}
-						}
-
-						// Normalize method
-						method = strings.ToUpper(strings.TrimSpace(method))
-						if method == "" {
-							method = "GET"
-						}
-
-						entry := &structs.HAREntry{
-							Request: structs.HARRequest{
-								Method:      method,
-								URL:         url,
-								HTTPVersion: "HTTP/1.1",
-								Cookies:     []structs.HARCookie{},
-								Headers:     []structs.HARNameValue{},
-								Query:       []structs.HARNameValue{},
-								PostData:    nil,
-								HeaderSize:  -1,
-								BodySize:    -1,
-							},
-						}
-
-						// Guess content type if missing
-						ctype = helper.GuessContentType(ctype, body, method)
-
-						// if ctype != "" {
-						// 	entry.Request.Headers = append(entry.Request.Headers, structs.HARNameValue{
-						// 		Name:  "Content-Type",
-						// 		Value: ctype,
-						// 	})
-						// }
-
-						// Fill query params if any
-						if strings.Contains(url, "?") {
-							qp := helper.ParseQueryParams(url)
-							for _, v := range qp {
-								entry.Request.Query = append(entry.Request.Query, structs.HARNameValue{
-									Name:  v.Name,
-									Value: v.Value,
-								})
-							}
-						}
-
-						// Fill post data entries if available
-						if body != "" {
-							entry.Request.PostData = &structs.HARPostData{
-								MimeType: ctype,
-								Text:     body,
-							}
-							entry.Request.BodySize = len(body)
-						}
-
-						results = append(results, entry)
+			case "new_expression":
+				ctor := bodyNode.ChildByFieldName("constructor")
+				if ctor != nil {
+					cname := ctor.Content(src)
+					if cname == "FormData" {
+						ctype = "multipart/form-data"
+					}
+					if cname == "Blob" || cname == "File" {
+						ctype = "application/octet-stream"
 					}
 				}
 			}
+		}
+	}
+
+	if reqHeaders == nil {
+		reqHeaders = w.extractHeaders(config)
+	}
+
+	client := prim
+	if inst != nil && inst.name != inst.lib {
+		client = inst.lib + ":" + inst.name
+	}
+	w.addEntry(client, method, url, body, ctype, reqHeaders)
+}
+
+// addEntry appends a statically found request to the results
+func (w *walker) addEntry(client, method, url, body, ctype string, reqHeaders []structs.HARNameValue) {
+	// Normalize method
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = "GET"
+	}
+
+	entry := &structs.HAREntry{
+		Client: client,
+		Request: structs.HARRequest{
+			Method:      method,
+			URL:         url,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []structs.HARCookie{},
+			Headers:     reqHeaders,
+			Query:       []structs.HARNameValue{},
+			PostData:    nil,
+			HeaderSize:  -1,
+			BodySize:    -1,
+		},
+	}
+
+	// Guess content type if missing
+	ctype = helper.GuessContentType(ctype, body, method)
+
+	if ctype != "" && body != "" && !helper.HasHeader(entry.Request.Headers, "Content-Type") {
+		entry.Request.Headers = append(entry.Request.Headers, structs.HARNameValue{
+			Name:  "Content-Type",
+			Value: ctype,
+		})
+	}
+
+	// Fill query params if any
+	if strings.Contains(url, "?") {
+		qp := helper.ParseQueryParams(url)
+		for _, v := range qp {
+			entry.Request.Query = append(entry.Request.Query, structs.HARNameValue{
+				Name:  v.Name,
+				Value: v.Value,
+			})
+		}
+	}
+
+	// Fill post data entries if available
+	if body != "" {
+		entry.Request.PostData = &structs.HARPostData{
+			MimeType: ctype,
+			Text:     body,
+		}
+		entry.Request.BodySize = len(body)
+	}
 
-			for i := 0; i < int(node.ChildCount()); i++ {
-				walk(node.Child(i))
+	w.results = append(w.results, entry)
+}
+
+// setHeaders adds hdrs to dst, replacing headers of the same name case-insensitively
+func setHeaders(dst, hdrs []structs.HARNameValue) []structs.HARNameValue {
+	for _, h := range hdrs {
+		replaced := false
+		for i := range dst {
+			if strings.EqualFold(dst[i].Name, h.Name) {
+				dst[i].Value = h.Value
+				replaced = true
 			}
 		}
+		if !replaced {
+			dst = append(dst, h)
+		}
+	}
+	return dst
+}
 
-		walk(root)
-		ch <- result{results, nil}
-	}()
+// appendQuery adds an encoded query string to url
+func appendQuery(url, query string) string {
+	if query == "" {
+		return url
+	}
+	if strings.Contains(url, "?") {
+		return url + "&" + query
+	}
+	return url + "?" + query
+}
 
-	// --- wait for worker ---
-	select {
-	case <-ctx.Done():
-		return nil, fmt.Errorf("JS parse timed out")
-	case res := <-ch:
-		return res.val, res.err
+// joinBaseURL resolves url against an axios baseURL, absolute URLs ignore it
+func joinBaseURL(baseURL, url string) string {
+	if baseURL == "" || strings.Contains(url, "://") || strings.HasPrefix(url, "//") {
+		return url
 	}
-	//return results, nil
+	if url == "" {
+		return baseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(url, "/")
 }
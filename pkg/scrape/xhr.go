@@ -0,0 +1,119 @@
+package scrape
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+var xhrMethods = map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true}
+
+// A request between open() and send() of one receiver
+type xhrRequest struct {
+	seq         int
+	method, url string
+	headers     []structs.HARNameValue
+}
+
+func (w *walker) isXHRConstructor(n *sitter.Node) bool {
+	switch w.constructorName(n) {
+	case "XMLHttpRequest":
+		return true
+	case "ActiveXObject":
+		return strings.Contains(strings.ToUpper(w.literal(w.firstArg(n))), "XMLHTTP")
+	}
+	return false
+}
+
+// collectXHR records the receivers assigned new XMLHttpRequest(), e.g. "xhr" or "this.req"
+func (w *walker) collectXHR(n *sitter.Node) {
+	switch n.Type() {
+	case "variable_declarator":
+		if name := n.ChildByFieldName("name"); name != nil && name.Type() == "identifier" && w.isXHRConstructor(n.ChildByFieldName("value")) {
+			w.xhrReceivers[name.Content(w.src)] = true
+		}
+	case "assignment_expression":
+		if left := w.memberPath(n.ChildByFieldName("left")); left != nil && w.isXHRConstructor(n.ChildByFieldName("right")) {
+			w.xhrReceivers[strings.Join(left, ".")] = true
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		w.collectXHR(n.NamedChild(i))
+	}
+}
+
+func (w *walker) emitXHR(r *xhrRequest, body string) {
+	ctype := ""
+	for _, h := range r.headers {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			ctype = h.Value
+		}
+	}
+	w.addEntry("XMLHttpRequest", r.method, r.url, body, ctype, r.headers)
+}
+
+// handleXHR pairs open(method, url), setRequestHeader(name, value) and send(body) on recv.
+// .open and .send of anything else (window.open, socket.send) is ignored.
+func (w *walker) handleXHR(recv, call string, args []*sitter.Node) {
+	switch call {
+	case "open":
+		// Unknown receivers count when the first argument is an HTTP method
+		method := w.literal(arg(args, 0))
+		if method != "" && !xhrMethods[strings.ToUpper(method)] {
+			return
+		}
+		if method == "" && !w.xhrReceivers[recv] {
+			return
+		}
+		// A previous open() that was never sent is still a request
+		if r, ok := w.pendingXHR[recv]; ok {
+			w.emitXHR(r, "")
+		}
+		w.xhrSeq++
+		r := &xhrRequest{seq: w.xhrSeq, method: method, url: w.extractString(arg(args, 1)), headers: []structs.HARNameValue{}}
+		// open(method, url, async, user, password)
+		if user, pass := w.constant(arg(args, 3)), w.constant(arg(args, 4)); user != "" && pass != "" {
+			r.headers = append(r.headers, structs.HARNameValue{Name: "Authorization", Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))})
+		}
+		w.pendingXHR[recv] = r
+	case "setRequestHeader":
+		r, ok := w.pendingXHR[recv]
+		name, value := w.constant(arg(args, 0)), w.constant(arg(args, 1))
+		if !ok || name == "" || value == "" {
+			return
+		}
+		r.headers = setHeaders(r.headers, []structs.HARNameValue{{Name: name, Value: value}})
+	case "send":
+		r, ok := w.pendingXHR[recv]
+		if !ok {
+			return
+		}
+		delete(w.pendingXHR, recv)
+		body := ""
+		if a := arg(args, 0); a != nil {
+			switch a.Type() {
+			case "string", "template_string", "object":
+				body = w.extractString(a)
+			case "call_expression", "new_expression":
+				// JSON.stringify(...), new FormData(...), GuessContentType recognizes them
+				body = a.Content(w.src)
+			}
+		}
+		w.emitXHR(r, body)
+	}
+}
+
+// flushXHR emits the requests opened but never sent in this file, in source order
+func (w *walker) flushXHR() {
+	var unsent []*xhrRequest
+	for _, r := range w.pendingXHR {
+		unsent = append(unsent, r)
+	}
+	sort.Slice(unsent, func(i, j int) bool { return unsent[i].seq < unsent[j].seq })
+	for _, r := range unsent {
+		w.emitXHR(r, "")
+	}
+}
@@ -0,0 +1,335 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-1tZ/reqtrack/pkg/cookies"
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/login"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// 401/403 responses only count as burst when they arrive within this window
+const burstWindow = 10 * time.Second
+
+// Upper bound of re-authentications per target, a recipe that keeps failing must not loop forever
+const maxRecoveries = 5
+
+// Config lists the logout signals to watch for, empty fields are disabled
+type Config struct {
+	LoginURL      string // regular expression, a redirect or main-frame navigation to a matching URL is a logout
+	StatusBurst   int    // number of 401/403 responses of the target host within 10s
+	LoginSelector string // CSS selector of a login form showing up on a crawled page
+	Cookie        string // name of the session cookie that must not disappear
+}
+
+// Enabled reports whether any signal is configured
+func (c Config) Enabled() bool {
+	return c.LoginURL != "" || c.StatusBurst > 0 || c.LoginSelector != "" || c.Cookie != ""
+}
+
+// Recovery restores a lost session, by the login recipe if set, by the storage state otherwise
+type Recovery struct {
+	Login        *login.Recipe
+	StorageState string
+	NavTimeout   float64 // milliseconds
+	Headers      *headers.Set
+	Cookies      []pw.OptionalCookie // imported with -cookies/-cookie, restored along with the storage state
+}
+
+// period in which requests were sent without a valid session, a zero to means still ongoing
+type interval struct {
+	from, to time.Time
+}
+
+// Monitor watches a BrowserContext for logout signals, re-authenticates and
+// remembers which requests were sent while the session was gone.
+type Monitor struct {
+	cfg      Config
+	recovery Recovery
+	loginURL *regexp.Regexp
+	host     string
+
+	mu         sync.Mutex
+	lost       bool
+	reason     string
+	recovering bool
+	recoveries int
+	okAt       time.Time              // last checkpoint with a valid session
+	failures   []time.Time            // recent 401/403 responses
+	cookieSeen bool                   // session cookie was present once
+	requests   map[string][]time.Time // "METHOD URL" -> send times
+	lostSpans  []interval
+}
+
+func New(cfg Config, recovery Recovery, targetURL string) (*Monitor, error) {
+	m := &Monitor{
+		cfg:      cfg,
+		recovery: recovery,
+		host:     strings.ToLower(helper.Hostname(targetURL)),
+		okAt:     time.Now(),
+		requests: make(map[string][]time.Time),
+	}
+	if cfg.LoginURL != "" {
+		re, err := regexp.Compile(cfg.LoginURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid login URL pattern: %w", err)
+		}
+		m.loginURL = re
+	}
+	return m, nil
+}
+
+// Install records every request of browserCtx and watches redirects, navigations and responses for logout signals
+func (m *Monitor) Install(browserCtx pw.BrowserContext) error {
+	if m == nil {
+		return nil
+	}
+	browserCtx.OnRequest(func(r pw.Request) {
+		now := time.Now()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		key := r.Method() + " " + r.URL()
+		m.requests[key] = append(m.requests[key], now)
+
+		if m.loginURL != nil && r.RedirectedFrom() != nil && r.IsNavigationRequest() && m.loginURL.MatchString(r.URL()) {
+			m.signal(now, "redirect to login page "+r.URL())
+		}
+	})
+	if m.loginURL != nil {
+		// Apps also send the user to the login page by location.href or the History API, without a redirect
+		browserCtx.OnPage(func(page pw.Page) {
+			page.OnFrameNavigated(func(f pw.Frame) {
+				if f.ParentFrame() != nil || !m.loginURL.MatchString(f.URL()) {
+					return
+				}
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				m.signal(time.Now(), "navigation to login page "+f.URL())
+			})
+		})
+	}
+	browserCtx.OnResponse(func(r pw.Response) {
+		if m.cfg.StatusBurst <= 0 || (r.Status() != 401 && r.Status() != 403) {
+			return
+		}
+		if !strings.EqualFold(helper.Hostname(r.URL()), m.host) {
+			return
+		}
+		now := time.Now()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.failures = append(m.failures, now)
+		for len(m.failures) > 0 && now.Sub(m.failures[0]) > burstWindow {
+			m.failures = m.failures[1:]
+		}
+		if len(m.failures) >= m.cfg.StatusBurst {
+			m.signal(m.failures[0], fmt.Sprintf("%d responses with 401/403", len(m.failures)))
+		}
+	})
+	return nil
+}
+
+// signal marks the session as lost since the given time, m.mu must be held
+func (m *Monitor) signal(since time.Time, reason string) {
+	if m.lost || m.recovering {
+		return
+	}
+	m.lost = true
+	m.reason = reason
+	m.lostSpans = append(m.lostSpans, interval{from: since})
+}
+
+// Inspect looks for the login form on a page before it gets closed
+func (m *Monitor) Inspect(page pw.Page) {
+	if m == nil || m.cfg.LoginSelector == "" {
+		return
+	}
+	n, err := page.Locator(m.cfg.LoginSelector).Count()
+	if err != nil || n == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.signal(m.okAt, "login form on "+page.URL())
+	m.mu.Unlock()
+}
+
+// Check is called between pages. It checks the session cookie and re-authenticates after a logout signal.
+// Returns true when the session was restored, the last page should then be captured again.
+func (m *Monitor) Check(browserCtx pw.BrowserContext) bool {
+	if m == nil {
+		return false
+	}
+	if m.cfg.Cookie != "" {
+		m.checkCookie(browserCtx)
+	}
+
+	m.mu.Lock()
+	if !m.lost {
+		m.okAt = time.Now()
+		m.mu.Unlock()
+		return false
+	}
+	if m.recoveries >= maxRecoveries || (m.recovery.Login == nil && m.recovery.StorageState == "") {
+		// Keep the span open, everything from here on is unauthenticated
+		m.mu.Unlock()
+		return false
+	}
+	reason := m.reason
+	m.recovering = true
+	m.recoveries++
+	m.lostSpans[len(m.lostSpans)-1].to = time.Now()
+	m.mu.Unlock()
+
+	log.Printf("Session: lost (%s), re-authenticating [%d/%d]", reason, m.recoveries, maxRecoveries)
+	err := m.restore(browserCtx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recovering = false
+	m.failures = nil
+	if err != nil {
+		log.Printf("Session: re-authentication failed: %v", err)
+		m.lostSpans = append(m.lostSpans, interval{from: time.Now()})
+		return false
+	}
+	m.lost = false
+	m.okAt = time.Now()
+	return true
+}
+
+func (m *Monitor) checkCookie(browserCtx pw.BrowserContext) {
+	jar, err := browserCtx.Cookies()
+	if err != nil {
+		log.Printf("Session: reading cookies failed: %v", err)
+		return
+	}
+	present := false
+	for _, c := range jar {
+		if c.Name == m.cfg.Cookie {
+			present = true
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if present {
+		m.cookieSeen = true
+		return
+	}
+	if m.cookieSeen {
+		m.signal(m.okAt, "cookie "+m.cfg.Cookie+" disappeared")
+	}
+}
+
+func (m *Monitor) restore(browserCtx pw.BrowserContext) error {
+	if m.recovery.Login != nil {
+		return m.recovery.Login.Run(browserCtx, m.recovery.NavTimeout, m.recovery.Headers)
+	}
+	return restoreState(browserCtx, m.recovery.StorageState, m.recovery.Cookies)
+}
+
+// restoreState replaces the cookies of browserCtx by the imported ones plus those of a storage-state file,
+// and writes the localStorage of every origin of the file
+func restoreState(browserCtx pw.BrowserContext, path string, imported []pw.OptionalCookie) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state pw.StorageState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := browserCtx.ClearCookies(); err != nil {
+		return err
+	}
+	// Cookies of the file come last, they win over imported ones of the same name
+	jar := append([]pw.OptionalCookie{}, imported...)
+	for _, c := range state.Cookies {
+		jar = append(jar, cookies.ToOptional(c))
+	}
+	if len(jar) > 0 {
+		if err := browserCtx.AddCookies(jar); err != nil {
+			return err
+		}
+	}
+	if len(state.Origins) == 0 {
+		return nil
+	}
+
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return err
+	}
+	defer page.Close()
+	// localStorage needs a document of the origin, an empty one is served without touching the server
+	err = page.Route("**/*", func(route pw.Route) {
+		route.Fulfill(pw.RouteFulfillOptions{Status: pw.Int(200), ContentType: pw.String("text/html"), Body: ""})
+	})
+	if err != nil {
+		return err
+	}
+	for _, o := range state.Origins {
+		if _, err := page.Goto(o.Origin); err != nil {
+			log.Printf("Session: restoring localStorage of %s failed: %v", o.Origin, err)
+			continue
+		}
+		_, err := page.Evaluate(`(items) => { for (const { name, value } of items) localStorage.setItem(name, value); }`, o.LocalStorage)
+		if err != nil {
+			log.Printf("Session: restoring localStorage of %s failed: %v", o.Origin, err)
+		}
+	}
+	return nil
+}
+
+// Annotate marks the browser HAR entries that were sent while the session was lost.
+// Repeated requests are told apart by the order in which the HAR lists them.
+func (m *Monitor) Annotate(entries []*structs.HAREntry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.lostSpans) == 0 {
+		return
+	}
+
+	nth := make(map[string]int)
+	marked := 0
+	for _, e := range entries {
+		key := e.Request.Method + " " + e.Request.URL
+		times := m.requests[key]
+		i := nth[key]
+		nth[key]++
+		if i >= len(times) {
+			continue
+		}
+		if m.inLostSpan(times[i]) {
+			e.SessionLost = true
+			marked++
+		}
+	}
+	log.Printf("Session: lost %d times, %d requests were sent without a valid session", len(m.lostSpans), marked)
+}
+
+func (m *Monitor) inLostSpan(t time.Time) bool {
+	for _, s := range m.lostSpans {
+		if !t.Before(s.from) && (s.to.IsZero() || t.Before(s.to)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package sockets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Session is a single WebSocket connection or EventSource stream
+type Session struct {
+	Kind     string                        `json:"kind"` // websocket or eventsource
+	URL      string                        `json:"url"`
+	Started  float64                       `json:"started"`
+	Closed   float64                       `json:"closed,omitempty"`
+	Error    string                        `json:"error,omitempty"`
+	Messages []structs.HARWebSocketMessage `json:"messages"`
+}
+
+// SSE event sent by the init script
+type sseEvent struct {
+	ID    string  `json:"id"`
+	URL   string  `json:"url"`
+	Event string  `json:"event"`
+	Data  string  `json:"data"`
+	Time  float64 `json:"time"`
+	Open  bool    `json:"open"`
+	Error bool    `json:"error"`
+}
+
+// Recorder records WebSocket frames of every page of a BrowserContext and
+// EventSource messages through an init script.
+type Recorder struct {
+	mu       sync.Mutex
+	sessions []*Session
+	sse      map[string]*Session
+}
+
+func New() *Recorder {
+	return &Recorder{sse: make(map[string]*Session)}
+}
+
+// Install hooks every page opened in browserCtx. Must be called before the first page is opened.
+func (r *Recorder) Install(browserCtx pw.BrowserContext) error {
+	if r == nil {
+		return nil
+	}
+	browserCtx.OnPage(func(page pw.Page) {
+		page.OnWebSocket(r.watch)
+	})
+
+	err := browserCtx.ExposeFunction("__reqtrackSSE", func(args ...interface{}) interface{} {
+		if len(args) == 0 {
+			return nil
+		}
+		raw, ok := args[0].(string)
+		if !ok {
+			return nil
+		}
+		r.addSSE(raw)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return browserCtx.AddInitScript(pw.Script{Content: pw.String(getSSEHookJS())})
+}
+
+func (r *Recorder) watch(ws pw.WebSocket) {
+	s := &Session{Kind: "websocket", URL: ws.URL(), Started: now(), Messages: []structs.HARWebSocketMessage{}}
+	r.mu.Lock()
+	r.sessions = append(r.sessions, s)
+	r.mu.Unlock()
+
+	ws.OnFrameSent(func(payload []byte) {
+		r.addFrame(s, "send", payload)
+	})
+	ws.OnFrameReceived(func(payload []byte) {
+		r.addFrame(s, "receive", payload)
+	})
+	ws.OnSocketError(func(msg string) {
+		r.mu.Lock()
+		s.Error = msg
+		r.mu.Unlock()
+	})
+	ws.OnClose(func(pw.WebSocket) {
+		r.mu.Lock()
+		s.Closed = now()
+		r.mu.Unlock()
+	})
+}
+
+// addFrame stores a frame, the opcode is derived from the payload since Playwright does not expose it
+func (r *Recorder) addFrame(s *Session, direction string, payload []byte) {
+	msg := structs.HARWebSocketMessage{Type: direction, Time: now(), Opcode: 1, Data: string(payload)}
+	if !utf8.Valid(payload) {
+		msg.Opcode = 2
+		msg.Data = base64.StdEncoding.EncodeToString(payload)
+	}
+	r.mu.Lock()
+	s.Messages = append(s.Messages, msg)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) addSSE(raw string) {
+	var ev sseEvent
+	if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+		log.Printf("Sockets: invalid SSE event: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sse[ev.ID]
+	if !ok {
+		s = &Session{Kind: "eventsource", URL: ev.URL, Started: ev.Time, Messages: []structs.HARWebSocketMessage{}}
+		r.sse[ev.ID] = s
+		r.sessions = append(r.sessions, s)
+	}
+	switch {
+	case ev.Open:
+	case ev.Error:
+		s.Error = "stream error"
+		s.Closed = ev.Time
+	default:
+		s.Messages = append(s.Messages, structs.HARWebSocketMessage{
+			Type:   "receive",
+			Time:   ev.Time,
+			Opcode: 1,
+			Data:   ev.Data,
+			Event:  ev.Event,
+		})
+	}
+}
+
+// Sessions returns a snapshot of all recorded sessions
+func (r *Recorder) Sessions() []*Session {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*Session, len(r.sessions))
+	copy(out, r.sessions)
+	return out
+}
+
+// Attach adds the messages of every session to the handshake entry of the browser HAR with the same URL
+// and returns new entries for sessions without one (EventSource streams, blocked handshakes, ...).
+func (r *Recorder) Attach(entries []*structs.HAREntry) []*structs.HAREntry {
+	if r == nil {
+		return nil
+	}
+	byURL := make(map[string]*structs.HAREntry, len(entries))
+	for _, e := range entries {
+		if e.Request.Method == "GET" {
+			if _, ok := byURL[e.Request.URL]; !ok {
+				byURL[e.Request.URL] = e
+			}
+		}
+	}
+
+	var missing []*structs.HAREntry
+	for _, s := range r.Sessions() {
+		e, ok := byURL[s.URL]
+		if !ok {
+			e = helper.NewHAREntry("GET", s.URL, nil, "", "")
+			e.Source = s.Kind
+			byURL[s.URL] = e
+			missing = append(missing, e)
+		}
+		e.ResourceType = s.Kind
+		e.WebSocketMessages = append(e.WebSocketMessages, s.Messages...)
+	}
+	return missing
+}
+
+// WriteTranscript writes all sessions as JSON to path
+func WriteTranscript(path string, sessions []*Session) error {
+	if sessions == nil {
+		sessions = []*Session{}
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sessions)
+}
+
+// HAR timestamps are seconds since epoch
+func now() float64 {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
+
+func getSSEHookJS() string {
+	return `(() => {
+		if (window.__reqtrackSSEHooked || !window.EventSource) return;
+		Object.defineProperty(window, '__reqtrackSSEHooked', { value: true, enumerable: false });
+
+		const prefix = Math.random().toString(36).slice(2);
+		let seq = 0;
+		const send = (ev) => {
+			ev.time = Date.now() / 1000;
+			try { window.__reqtrackSSE(JSON.stringify(ev)); } catch (e) {}
+		};
+
+		window.EventSource = new Proxy(window.EventSource, {
+			construct(target, args, newTarget) {
+				const es = Reflect.construct(target, args, newTarget);
+				const id = prefix + '-' + (++seq);
+				const url = es.url;
+				const hooked = new Set();
+				const hook = (type) => {
+					if (hooked.has(type)) return;
+					hooked.add(type);
+					EventTarget.prototype.addEventListener.call(es, type, (m) => send({ id, url, event: type, data: String(m.data) }));
+				};
+
+				send({ id, url, open: true });
+				hook('message');
+				EventTarget.prototype.addEventListener.call(es, 'error', () => send({ id, url, error: true }));
+
+				// Named events only arrive at listeners registered for them
+				es.addEventListener = function(type) {
+					if (type !== 'open' && type !== 'error') hook(type);
+					return EventTarget.prototype.addEventListener.apply(this, arguments);
+				};
+				return es;
+			},
+		});
+	})();`
+}
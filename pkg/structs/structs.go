@@ -19,7 +19,54 @@ type HARLog struct {
 
 // ∙∙∙ REQUEST ENTRY ∙∙∙
 type HAREntry struct {
-	Request HARRequest `json:"request"`
+	Request   HARRequest    `json:"request"`
+	Source    string        `json:"_source,omitempty"` // how the entry was discovered, e.g. robots.txt or sitemap
+	Frame     string        `json:"_frame,omitempty"`  // URL of the child frame the request came from
+	Initiator *HARInitiator `json:"_initiator,omitempty"`
+	Client    string        `json:"_client,omitempty"` // primitive or client instance of a statically found call, e.g. fetch or axios:api
+	Status    int           `json:"_status,omitempty"` // response status seen by the browser
+
+	// Anti-CSRF token the request carries or needs
+	CSRF *HARCSRF `json:"_csrf,omitempty"`
+
+	// Sent after a logout signal and before the session was restored
+	SessionLost bool `json:"_sessionLost,omitempty"`
+
+	// Configured header values the request tried to carry to a host they are not meant for
+	Leaks []HARLeak `json:"_leaks,omitempty"`
+
+	// WebSocket handshakes and EventSource streams
+	ResourceType      string                `json:"_resourceType,omitempty"`
+	WebSocketMessages []HARWebSocketMessage `json:"_webSocketMessages,omitempty"`
+}
+
+// Single WebSocket frame or EventSource message
+type HARWebSocketMessage struct {
+	Type   string  `json:"type"` // send or receive
+	Time   float64 `json:"time"` // seconds since epoch
+	Opcode int     `json:"opcode"`
+	Data   string  `json:"data"`
+	Event  string  `json:"event,omitempty"` // EventSource event name
+}
+
+// Code that issued a request, recorded by the runtime instrumentation
+type HARInitiator struct {
+	Type  string `json:"type"` // fetch, xhr, beacon, websocket, eventsource or worker
+	Stack string `json:"stack,omitempty"`
+	Error string `json:"error,omitempty"` // set when the call failed or was blocked (e.g. CORS)
+}
+
+// Anti-CSRF token of a request
+type HARCSRF struct {
+	Name   string `json:"name"`   // header or parameter name
+	In     string `json:"in"`     // header, query or body
+	Source string `json:"source"` // where the token was found: meta, input, cookie or header
+}
+
+// Configured header value found in a request to another host
+type HARLeak struct {
+	Header string `json:"header"` // configured header whose value leaked
+	Where  string `json:"where"`  // request header name (stripped), url or body (reported only)
 }
 
 // Request section
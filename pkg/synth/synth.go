@@ -0,0 +1,114 @@
+package synth
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/m-1tZ/reqtrack/pkg/headers"
+	"github.com/m-1tZ/reqtrack/pkg/helper"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
+)
+
+// Headers copied from dynamic requests: credentials and what servers check for CSRF
+var copied = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"origin":        true,
+	"referer":       true,
+	"user-agent":    true,
+}
+
+// profile of the requests the browser sent to one host
+type profile struct {
+	headers      map[string]structs.HARNameValue // lower-cased name -> last seen header
+	cookies      []structs.HARCookie
+	contentTypes map[string]string // "METHOD URL" -> Content-Type of a request with body
+}
+
+// Apply makes statically scraped entries replayable. Every entry gets the credentials, cookies,
+// Origin/Referer and User-Agent the browser sent to the same host, headers taken from the
+// call itself are kept. Hosts the browser never talked to only get Origin/Referer of the target.
+func Apply(scraped []*structs.HAREntry, observed []*structs.HAREntry, targetURL string) {
+	baseOrigin, err := helper.Origin(targetURL)
+	if err != nil {
+		return
+	}
+
+	profiles := make(map[string]*profile)
+	for _, e := range observed {
+		// Requests sent without a session would spread stale credentials
+		if e.SessionLost {
+			continue
+		}
+		host := strings.ToLower(helper.Hostname(e.Request.URL))
+		if host == "" {
+			continue
+		}
+		p := profiles[host]
+		if p == nil {
+			p = &profile{headers: make(map[string]structs.HARNameValue), contentTypes: make(map[string]string)}
+			profiles[host] = p
+		}
+		for _, h := range e.Request.Headers {
+			name := strings.ToLower(h.Name)
+			if copied[name] || headers.IsCredential(name) {
+				p.headers[name] = h
+			}
+			if name == "content-type" && e.Request.PostData != nil {
+				p.contentTypes[e.Request.Method+" "+e.Request.URL] = h.Value
+			}
+		}
+		if len(e.Request.Cookies) > 0 {
+			p.cookies = e.Request.Cookies
+		}
+	}
+
+	for _, e := range scraped {
+		req := &e.Request
+		abs := helper.SanitizeURL(req.URL, baseOrigin)
+		host := strings.ToLower(helper.Hostname(abs))
+		if host == "" {
+			continue
+		}
+		p := profiles[host]
+		if p == nil {
+			p = &profile{headers: map[string]structs.HARNameValue{
+				"referer": {Name: "Referer", Value: targetURL},
+			}}
+			if req.Method != "GET" && req.Method != "HEAD" {
+				p.headers["origin"] = structs.HARNameValue{Name: "Origin", Value: baseOrigin}
+			}
+		}
+
+		names := make([]string, 0, len(p.headers))
+		for name := range p.headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !helper.HasHeader(req.Headers, name) {
+				req.Headers = append(req.Headers, p.headers[name])
+			}
+		}
+		if len(req.Cookies) == 0 && len(p.cookies) > 0 {
+			req.Cookies = append([]structs.HARCookie{}, p.cookies...)
+		}
+
+		// The browser knows the real Content-Type of the same call
+		if ct, ok := p.contentTypes[req.Method+" "+abs]; ok && req.PostData != nil {
+			req.PostData.MimeType = ct
+			setHeader(req, "Content-Type", ct)
+		}
+	}
+}
+
+// setHeader replaces a header case-insensitively or appends it
+func setHeader(req *structs.HARRequest, name, value string) {
+	for i, h := range req.Headers {
+		if strings.EqualFold(h.Name, name) {
+			req.Headers[i].Value = value
+			return
+		}
+	}
+	req.Headers = append(req.Headers, structs.HARNameValue{Name: name, Value: value})
+}
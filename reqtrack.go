@@ -3,35 +3,200 @@ package main
 import (
 	"flag"
 	"log"
-	"time"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/m-1tZ/reqtrack/pkg/capture"
+	"github.com/m-1tZ/reqtrack/pkg/cookies"
+	"github.com/m-1tZ/reqtrack/pkg/credentials"
+	"github.com/m-1tZ/reqtrack/pkg/headers"
 	"github.com/m-1tZ/reqtrack/pkg/helper"
-	"github.com/m-1tZ/reqtrack/pkg/scrape"
+	"github.com/m-1tZ/reqtrack/pkg/login"
+	"github.com/m-1tZ/reqtrack/pkg/roles"
+	"github.com/m-1tZ/reqtrack/pkg/runner"
+	"github.com/m-1tZ/reqtrack/pkg/safety"
+	"github.com/m-1tZ/reqtrack/pkg/scope"
+	"github.com/m-1tZ/reqtrack/pkg/session"
+	"github.com/m-1tZ/reqtrack/pkg/sockets"
+	"github.com/m-1tZ/reqtrack/pkg/structs"
 	pw "github.com/playwright-community/playwright-go"
 )
 
+const defaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:144.0) Gecko/20100101 Firefox/144.0"
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// orDefault returns the non-empty values, def when the flag was never given
+func (l *stringList) orDefault(def []string) []string {
+	if *l == nil {
+		return def
+	}
+	var out []string
+	for _, v := range *l {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func main() {
 	var targetURL string
-	var header string
+	var headerFlags stringList
+	var headerFile string
 	var parseTimeout float64
 	var navTimeout float64
 	var proxy string
 	var harPath string
+	var depth int
+	var maxPages int
+	var includeHosts string
+	var excludeHosts string
+	var includePaths string
+	var excludePaths string
+	var scopePorts string
+	var scopeSchemes string
+	var scopeFile string
+	var discovery bool
+	var listPath string
+	var concurrency int
+	var outDir string
+	var denyText stringList
+	var denySelectors stringList
+	var logoutPattern string
+	var methodPolicy string
+	var instrumentation bool
+	var socketTranscript bool
+	var cookieFile string
+	var cookieHeader string
+	var saveCookies string
+	var storageState string
+	var saveStorageState string
+	var loginRecipe string
+	var sessionCfg session.Config
+	var rolesFile string
+	var httpAuth string
+	var clientCerts stringList
 
-	flag.StringVar(&header, "H",
-		"User-Agent: Mozilla/5.0 (X11; Linux x86_64; rv:144.0) Gecko/20100101 Firefox/144.0",
-		"Custom header")
+	flag.Var(&headerFlags, "H", "Custom header \"Name: value\", \"[host-glob] Name: value\" for a single host (repeatable)")
+	flag.StringVar(&headerFile, "header-file", "", "File with one header per line, [host-glob] lines start per-host sections")
 	flag.StringVar(&targetURL, "u", "", "URL to process")
+	flag.StringVar(&listPath, "l", "", "File with target URLs, one per line (- for stdin)")
+	flag.IntVar(&concurrency, "c", 3, "Number of targets processed concurrently (default 3)")
 	flag.Float64Var(&parseTimeout, "tparse", 30, "Timeout for parsing of scripts with AST (default 30s)")
 	flag.Float64Var(&navTimeout, "tnav", 7, "Timeout for navigation and script evaluation (default 7s)")
 	flag.StringVar(&proxy, "p", "", "Optional proxy (http://127.0.0.1:8080)")
 	flag.StringVar(&harPath, "har", "traffic.har", "HAR output file")
+	flag.StringVar(&outDir, "o", "", "Write one HAR file per target into this directory instead of a combined -har file")
+	flag.IntVar(&depth, "depth", 1, "Maximum crawl depth from the target URL, 0 only visits the target (default 1)")
+	flag.IntVar(&maxPages, "max-pages", 25, "Maximum number of pages to visit, 0 for unlimited (default 25)")
+	flag.StringVar(&includeHosts, "include-host", "", "Comma separated in-scope host globs (default: host of each target)")
+	flag.StringVar(&excludeHosts, "exclude-host", "", "Comma separated out-of-scope host globs")
+	flag.StringVar(&includePaths, "include-path", "", "Comma separated in-scope path regexes")
+	flag.StringVar(&excludePaths, "exclude-path", "", "Comma separated out-of-scope path regexes")
+	flag.StringVar(&scopePorts, "scope-ports", "", "Comma separated in-scope ports")
+	flag.StringVar(&scopeSchemes, "scope-schemes", "", "Comma separated in-scope schemes")
+	flag.StringVar(&scopeFile, "scope-file", "", "File with scope rules")
+	flag.BoolVar(&discovery, "discover", false, "Seed the crawl from robots.txt, sitemap.xml and well-known files")
+	flag.Var(&denyText, "deny-text", "Regex, elements with matching text are never triggered (repeatable, replaces the defaults, \"\" disables)")
+	flag.Var(&denySelectors, "deny-selector", "CSS selector of elements that are never triggered (repeatable, replaces the defaults, \"\" disables)")
+	flag.StringVar(&logoutPattern, "logout-pattern", safety.DefaultLogoutPattern, "Regex of logout URLs, matching requests are blocked (empty disables)")
+	flag.StringVar(&methodPolicy, "method-policy", safety.MethodAllow, "Handling of POST/PUT/PATCH/DELETE requests: allow, record or block")
+	flag.BoolVar(&instrumentation, "instrument", true, "Wrap fetch, XHR, sendBeacon, WebSocket, EventSource and Worker to record initiators and failed calls")
+	flag.BoolVar(&socketTranscript, "sockets", false, "Write a WebSocket/EventSource transcript (<har>.sockets.json) next to each HAR file")
+	flag.StringVar(&cookieFile, "cookies", "", "Load cookies from a Netscape cookies.txt or a JSON export")
+	flag.StringVar(&cookieHeader, "cookie", "", "Raw cookie header (\"a=b; c=d\"), only sent to the target's origin")
+	flag.StringVar(&saveCookies, "save-cookies", "", "Write the final cookie jar to this file (.json for JSON, Netscape format otherwise)")
+	flag.StringVar(&storageState, "storage-state", "", "Load a Playwright storage-state file (cookies and localStorage) into every context")
+	flag.StringVar(&saveStorageState, "save-storage-state", "", "Write the final storage state (cookies and localStorage) to this file")
+	flag.StringVar(&loginRecipe, "login", "", "Login recipe (YAML/JSON) executed before capture")
+	flag.StringVar(&sessionCfg.LoginURL, "session-login-url", "", "Regex of the login page, a redirect or navigation to it means the session was lost")
+	flag.IntVar(&sessionCfg.StatusBurst, "session-status-burst", 0, "Number of 401/403 responses within 10s that mean the session was lost (0 disables)")
+	flag.StringVar(&sessionCfg.LoginSelector, "session-login-selector", "", "CSS selector of the login form, its appearance means the session was lost")
+	flag.StringVar(&sessionCfg.Cookie, "session-cookie", "", "Name of the session cookie, its disappearance means the session was lost")
+	flag.StringVar(&rolesFile, "roles", "", "Roles file (YAML/JSON), captures every target once per role and writes a diff report")
+	flag.StringVar(&httpAuth, "http-auth", "", "Basic/Digest credentials as [origin=]user:password, bound to the target origin if none is given")
+	flag.Var(&clientCerts, "client-cert", "Client certificate as origin=cert.pem,key.pem[,passphrase] or origin=client.p12[,passphrase] (repeatable)")
 
 	flag.Parse()
 
-	if targetURL == "" {
-		log.Fatal("Missing -u URL")
+	// ---- Targets ----
+	var targets []string
+	if targetURL != "" {
+		targets = append(targets, targetURL)
+	}
+	if listPath != "" {
+		in := os.Stdin
+		if listPath != "-" {
+			f, err := os.Open(listPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			in = f
+		}
+		lines, err := helper.ReadLines(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		targets = append(targets, lines...)
+	}
+	// Piped stdin without -u and -l
+	if len(targets) == 0 {
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+			lines, err := helper.ReadLines(os.Stdin)
+			if err != nil {
+				log.Fatal(err)
+			}
+			targets = lines
+		}
+	}
+	if len(targets) == 0 {
+		log.Fatal("Missing -u URL, -l list or targets on stdin")
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// ---- Scope (shared rules, the target host is added per target) ----
+	sc := scope.New()
+	if scopeFile != "" {
+		if err := sc.LoadFile(scopeFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, r := range []struct {
+		kind    string
+		list    string
+		include bool
+	}{
+		{"host", includeHosts, true},
+		{"host", excludeHosts, false},
+		{"path", includePaths, true},
+		{"path", excludePaths, false},
+		{"port", scopePorts, true},
+		{"scheme", scopeSchemes, true},
+	} {
+		if err := sc.AddRules(r.kind, r.list, r.include); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	// ---- Playwright Setup ----
@@ -43,6 +208,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer pwRunner.Stop()
 
 	launchOpts := pw.BrowserTypeLaunchOptions{
 		Headless: pw.Bool(true),
@@ -55,67 +221,254 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer browser.Close()
 
-	// ---- Parse header flag ----
-	hKey, hVal := helper.ParseHeaderFlag(header)
+	// ---- Headers (flags override the file) ----
+	hdr := headers.New()
+	hdr.Global["User-Agent"] = defaultUserAgent
+	if headerFile != "" {
+		if err := hdr.LoadFile(headerFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, h := range headerFlags {
+		if err := hdr.Add(h); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// ---- Browser Context with HAR ----
-	browserCtx, err := browser.NewContext(pw.BrowserNewContextOptions{
-		RecordHarPath: pw.String(harPath),
-		RecordHarMode: pw.HarModeFull,
-		ExtraHttpHeaders: map[string]string{
-			hKey: hVal,
-		},
-		IgnoreHttpsErrors: pw.Bool(true),
-	})
-	if err != nil {
-		log.Fatal(err)
+	// ---- Cookies ----
+	var jar []pw.OptionalCookie
+	if cookieFile != "" {
+		jar, err = cookies.Load(cookieFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Loaded %d cookies from %s", len(jar), cookieFile)
 	}
 
-	// browserCtx.SetDefaultTimeout(float64((2 * time.Second) / time.Millisecond)) // 30s
-	browserCtx.SetDefaultNavigationTimeout(float64((time.Duration(navTimeout) * time.Second) / time.Millisecond))
+	if storageState != "" {
+		if _, err := os.Stat(storageState); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	page, err := browserCtx.NewPage()
-	if err != nil {
-		log.Fatal(err)
+	var recipe *login.Recipe
+	if loginRecipe != "" {
+		recipe, err = login.Load(loginRecipe)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// ---- CAPTURE (navigate + JS triggers) ----
-	if err = capture.CaptureRequests(page, targetURL); err != nil {
-		log.Fatal(err)
+	var roleList []*roles.Role
+	if rolesFile != "" {
+		roleList, err = roles.Load(rolesFile, hdr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if saveCookies != "" || saveStorageState != "" || socketTranscript {
+			log.Printf("-save-cookies, -save-storage-state and -sockets are ignored with -roles")
+		}
 	}
 
-	// ---- SCRAPE (static / heuristics) ----
-	scrapeHarEntries, err := scrape.ScrapeRequests(page, browserCtx, targetURL, float64((time.Duration(navTimeout)*time.Second)/time.Millisecond), parseTimeout)
-	if err != nil {
-		log.Fatal(err)
+	// ---- HTTP auth and client certificates ----
+	var httpCreds *pw.HttpCredentials
+	if httpAuth != "" {
+		httpCreds, err = credentials.ParseHTTPAuth(httpAuth)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var certs []pw.ClientCertificate
+	for _, c := range clientCerts {
+		cert, err := credentials.ParseClientCert(c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		certs = append(certs, cert)
 	}
 
-	// Scrape results can only be integrated if .har file was written
-	// loop over har file and remove response objects. add scraped objects and unique the requests
+	opts := runner.Options{
+		Headers:      hdr,
+		NavTimeout:   navTimeout,
+		ParseTimeout: parseTimeout,
+		MaxDepth:     depth,
+		MaxPages:     maxPages,
+		Discover:     discovery,
+		Scope:        sc,
+		Safety: safety.Config{
+			DenyText:      denyText.orDefault(safety.DefaultDenyText),
+			DenySelectors: denySelectors.orDefault(safety.DefaultDenySelectors),
+			LogoutPattern: logoutPattern,
+			MethodPolicy:  methodPolicy,
+		},
+		Instrument:   instrumentation,
+		Cookies:      jar,
+		CookieHeader: cookieHeader,
+		StorageState: storageState,
+		Login:        recipe,
+		Session:      sessionCfg,
 
-	// ---- Close so HAR gets written ----
-	if err = browserCtx.Close(); err != nil {
-		log.Fatal(err)
+		HttpCredentials:    httpCreds,
+		ClientCertificates: certs,
 	}
 
-	log.Printf("Done. HAR will now be deduplicated and minified")
-	entries, err := helper.LoadHAREntriesStreaming(harPath)
-	if err != nil {
+	// Fail fast on invalid safety and session settings instead of once per target
+	if _, err := safety.New(opts.Safety); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := session.New(opts.Session, session.Recovery{}, ""); err != nil {
 		log.Fatal(err)
 	}
 
-	// ---- MERGE SCRAPED + HAR LOADED ----
-	merged := helper.MergeHAREntries(entries, scrapeHarEntries)
+	// ---- RUN TARGETS (one BrowserContext each, bounded concurrency) ----
+	var mu sync.Mutex
+	var combined []*structs.HAREntry
+	var combinedSockets []*sockets.Session
+	var combinedCookies []pw.Cookie
+	var combinedStates []*pw.StorageState
+	combinedRoles := make(map[string][]*structs.HAREntry)
+	var reports []*roles.Report
+	failed := 0
 
-	deduped, err := helper.DeduplicateHAREntries(merged, targetURL)
-	if err != nil {
-		log.Fatal(err)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	names := runner.OutputNames(targets)
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t, harName string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if len(roleList) > 0 {
+				results, report, err := roles.Run(browser, t, opts, roleList)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Printf("Target %s failed: %v", t, err)
+					failed++
+					return
+				}
+				if outDir != "" {
+					out := filepath.Join(outDir, harName)
+					for name, res := range results {
+						if err := helper.WriteHAR(rolePath(out, name), res.Entries); err != nil {
+							log.Printf("Writing HAR of role %s for %s failed: %v", name, t, err)
+						}
+					}
+					if err := roles.WriteReports(reportPath(out), []*roles.Report{report}); err != nil {
+						log.Printf("Writing role report for %s failed: %v", t, err)
+					}
+					log.Printf("Done. HARs and role report for %s saved next to: %s", t, out)
+					return
+				}
+				for name, res := range results {
+					combinedRoles[name] = append(combinedRoles[name], res.Entries...)
+				}
+				reports = append(reports, report)
+				return
+			}
+
+			res, err := runner.Run(browser, t, opts)
+			if err != nil {
+				// A failing target must not abort the batch
+				log.Printf("Target %s failed: %v", t, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return
+			}
+
+			if outDir != "" {
+				out := filepath.Join(outDir, harName)
+				if err := helper.WriteHAR(out, res.Entries); err != nil {
+					log.Printf("Writing HAR for %s failed: %v", t, err)
+					return
+				}
+				log.Printf("Done. HAR for %s saved at: %s", t, out)
+				if socketTranscript {
+					if err := sockets.WriteTranscript(transcriptPath(out), res.Sockets); err != nil {
+						log.Printf("Writing socket transcript for %s failed: %v", t, err)
+					}
+				}
+				if saveCookies != "" {
+					jarPath := strings.TrimSuffix(out, ".har") + ".cookies" + filepath.Ext(saveCookies)
+					if err := cookies.Save(jarPath, res.Cookies); err != nil {
+						log.Printf("Writing cookie jar for %s failed: %v", t, err)
+					}
+				}
+				if saveStorageState != "" {
+					statePath := strings.TrimSuffix(out, ".har") + ".state.json"
+					if err := cookies.SaveState(statePath, []*pw.StorageState{res.State}); err != nil {
+						log.Printf("Writing storage state for %s failed: %v", t, err)
+					}
+				}
+				return
+			}
+
+			mu.Lock()
+			combined = append(combined, res.Entries...)
+			combinedSockets = append(combinedSockets, res.Sockets...)
+			combinedCookies = append(combinedCookies, res.Cookies...)
+			combinedStates = append(combinedStates, res.State)
+			mu.Unlock()
+		}(t, names[i])
 	}
+	wg.Wait()
 
-	err = helper.WriteHAR(harPath, deduped)
-	if err != nil {
-		log.Fatal(err)
+	if outDir == "" && len(roleList) > 0 {
+		for _, r := range roleList {
+			if err := helper.WriteHAR(rolePath(harPath, r.Name), combinedRoles[r.Name]); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := roles.WriteReports(reportPath(harPath), reports); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Done. Role report saved at: %s", reportPath(harPath))
+	} else if outDir == "" {
+		err = helper.WriteHAR(harPath, combined)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Done. HAR saved at: %s", harPath)
+		if socketTranscript {
+			if err := sockets.WriteTranscript(transcriptPath(harPath), combinedSockets); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Socket transcript saved at: %s", transcriptPath(harPath))
+		}
+		if saveCookies != "" {
+			if err := cookies.Save(saveCookies, combinedCookies); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Cookie jar saved at: %s", saveCookies)
+		}
+		if saveStorageState != "" {
+			if err := cookies.SaveState(saveStorageState, combinedStates); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Storage state saved at: %s", saveStorageState)
+		}
+	}
+	if failed > 0 {
+		log.Printf("%d of %d targets failed", failed, len(targets))
 	}
-	log.Printf("Done. HAR saved at: %s", harPath)
+}
+
+// rolePath returns the HAR file of a single role that belongs to a HAR file
+func rolePath(harPath string, role string) string {
+	return strings.TrimSuffix(harPath, ".har") + "." + role + ".har"
+}
+
+// reportPath returns the role diff report that belongs to a HAR file
+func reportPath(harPath string) string {
+	return strings.TrimSuffix(harPath, ".har") + ".roles.json"
+}
+
+// transcriptPath returns the socket transcript file that belongs to a HAR file
+func transcriptPath(harPath string) string {
+	return strings.TrimSuffix(harPath, ".har") + ".sockets.json"
 }